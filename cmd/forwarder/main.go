@@ -6,10 +6,13 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"telegram_bot/telegram-pin-forwarder/internal/config"
 	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
 	"telegram_bot/telegram-pin-forwarder/internal/telegram"
 
 	"github.com/robfig/cron/v3"
@@ -19,9 +22,12 @@ func main() {
 	// Флаги командной строки
 	initFlag := flag.Bool("init", false, "Инициализация конфигурации")
 	onceFlag := flag.Bool("once", false, "Однократный запуск")
+	deadLettersFlag := flag.Bool("dead-letters", false, "Показать сообщения, которые не удалось отправить")
+	requeueFlag := flag.Int64("requeue", 0, "Вернуть неотправленное сообщение с указанным ID в очередь")
 	flag.Parse()
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	// Загружаем конфигурацию
 	cfg, err := config.LoadConfig()
@@ -35,20 +41,69 @@ func main() {
 		return
 	}
 
-	// Проверяем обязательные параметры
-	if cfg.Telegram.BotToken == "" {
-		log.Fatal("Не указан токен бота (telegram.bot_token)")
-	}
-	if cfg.Telegram.GroupChatID == 0 {
-		log.Fatal("Не указан ID группы (telegram.group_chat_id)")
-	}
-
 	// Проверяем количество дней для проверки событий
 	if cfg.App.DaysAhead < 1 {
 		cfg.App.DaysAhead = 5
 		log.Printf("Используется значение по умолчанию для days_ahead: %d", cfg.App.DaysAhead)
 	}
 
+	// Проверяем параметры очереди отправки
+	if cfg.App.Outbox.MaxAttempts < 1 || cfg.App.Outbox.BaseBackoff <= 0 || cfg.App.Outbox.MaxBackoff < cfg.App.Outbox.BaseBackoff || cfg.App.Outbox.PollInterval <= 0 {
+		log.Fatal("Некорректные параметры очереди отправки (app.outbox)")
+	}
+
+	// Проверяем этапы напоминаний
+	for _, days := range cfg.App.ReminderStages {
+		if days < 0 {
+			log.Fatalf("Некорректный этап напоминания в app.reminder_stages: %d", days)
+		}
+	}
+
+	// Проверяем режим получения обновлений
+	switch cfg.App.Mode {
+	case config.ModePolling:
+	case config.ModeWebhook:
+		if cfg.App.Webhook.URL == "" || !strings.HasPrefix(cfg.App.Webhook.Path, "/") {
+			log.Fatal("Для режима webhook нужно указать app.webhook.url и app.webhook.path, начинающийся с /")
+		}
+		if cfg.App.Webhook.SecretToken == "" {
+			log.Fatal("Для режима webhook нужно указать app.webhook.secret_token: без него запросы к вебхуку не проверяются")
+		}
+	default:
+		log.Fatalf("Неизвестный режим app.mode: %q (ожидается polling или webhook)", cfg.App.Mode)
+	}
+
+	// Проверяем разметку напоминаний
+	var parseMode string
+	switch cfg.App.ParseMode {
+	case config.ParseModePlain:
+	case config.ParseModeHTML, config.ParseModeMarkdownV2:
+		parseMode = cfg.App.ParseMode
+	default:
+		log.Fatalf("Неизвестная разметка app.parse_mode: %q (ожидается plain, HTML или MarkdownV2)", cfg.App.ParseMode)
+	}
+
+	// Проверяем язык сообщений по умолчанию
+	loc, ok := locale.Get(cfg.App.Locale)
+	if !ok {
+		log.Fatalf("Неизвестный язык app.locale: %q (поддерживаются: %s)", cfg.App.Locale, strings.Join(locale.Codes(), ", "))
+	}
+
+	// Проверяем шаблоны сообщений
+	templateTexts, err := cfg.App.Templates.Load()
+	if err != nil {
+		log.Fatalf("Ошибка загрузки шаблонов сообщений: %v", err)
+	}
+	templates, err := telegram.ParseTemplates(telegram.TemplateTexts{
+		Header: templateTexts.Header,
+		Line:   templateTexts.Line,
+		Footer: templateTexts.Footer,
+		Empty:  templateTexts.Empty,
+	})
+	if err != nil {
+		log.Fatalf("Некорректный шаблон сообщения (app.templates): %v", err)
+	}
+
 	// Подключаемся к базе данных
 	db, err := database.NewDatabase(ctx, cfg.GetDatabaseURL())
 	if err != nil {
@@ -59,47 +114,162 @@ func main() {
 	// Создаем репозиторий
 	repo := database.NewRepository(db)
 
+	// Команды для работы с очередью отправки не требуют бота
+	if *deadLettersFlag {
+		if err := listDeadLetters(ctx, repo); err != nil {
+			log.Fatalf("Ошибка получения неотправленных сообщений: %v", err)
+		}
+		return
+	}
+	if *requeueFlag != 0 {
+		if err := repo.RequeueDeadLetter(ctx, *requeueFlag); err != nil {
+			log.Fatalf("Ошибка возврата сообщения в очередь: %v", err)
+		}
+		log.Printf("Сообщение %d возвращено в очередь и будет отправлено при следующем запуске", *requeueFlag)
+		return
+	}
+
+	// Проверяем обязательные параметры
+	if cfg.Telegram.BotToken == "" {
+		log.Fatal("Не указан токен бота (telegram.bot_token)")
+	}
+	sources := cfg.GetSources()
+	if len(sources) == 0 {
+		log.Fatal("Не указаны группы (telegram.sources или telegram.group_chat_id)")
+	}
+	seen := make(map[int64]bool)
+	for _, source := range sources {
+		if source.ChatID == 0 || seen[source.ChatID] {
+			log.Fatalf("Некорректный или повторяющийся chat_id в telegram.sources: %d", source.ChatID)
+		}
+		seen[source.ChatID] = true
+	}
+
+	// Записи, созданные до поддержки нескольких групп, относятся к прежней группе
+	legacyChatID := cfg.Telegram.GroupChatID
+	if legacyChatID == 0 {
+		legacyChatID = sources[0].ChatID
+	}
+	if err := repo.AdoptLegacySourceRows(ctx, legacyChatID); err != nil {
+		log.Fatalf("Ошибка привязки старых записей к группе %d: %v", legacyChatID, err)
+	}
+
 	// Добавляем пользователей из конфигурации в базу данных
-	for _, userID := range cfg.Telegram.UserIDs {
+	userIDs := append([]int64{}, cfg.Telegram.UserIDs...)
+	for _, source := range sources {
+		userIDs = append(userIDs, source.UserIDs...)
+	}
+	for _, userID := range userIDs {
 		if err := repo.UpsertRecipient(ctx, userID, ""); err != nil {
 			log.Printf("Предупреждение: не удалось добавить получателя %d: %v", userID, err)
 		}
 	}
 
+	forwarderSources := make([]telegram.Source, 0, len(sources))
+	for _, source := range sources {
+		forwarderSources = append(forwarderSources, telegram.Source{
+			ChatID:    source.ChatID,
+			Name:      source.Name,
+			DaysAhead: source.DaysAhead,
+			UserIDs:   source.UserIDs,
+		})
+	}
+
 	// Создаем форвардер
-	forwarder, err := telegram.NewForwarder(cfg.Telegram.BotToken, repo, cfg.App.DaysAhead)
+	forwarder, err := telegram.NewForwarder(cfg.Telegram.BotToken, repo, telegram.Options{
+		Sources:              forwarderSources,
+		DaysAhead:            cfg.App.DaysAhead,
+		ReminderStages:       cfg.App.ReminderStages,
+		SameDayHoursBefore:   cfg.App.SameDayHoursBefore,
+		MaxSendAttempts:      cfg.App.Outbox.MaxAttempts,
+		RetryBaseBackoff:     cfg.App.Outbox.BaseBackoff,
+		RetryMaxBackoff:      cfg.App.Outbox.MaxBackoff,
+		MessagesPerSecond:    cfg.App.RateLimit.MessagesPerSecond,
+		PerChatInterval:      cfg.App.RateLimit.PerChatInterval,
+		NotifyAdminsOnChange: cfg.App.NotifyAdminsOnChange,
+		SendOnChange:         cfg.App.SendOnChange,
+		PinHashtag:           cfg.App.PinHashtag,
+		ParseMode:            parseMode,
+		Templates:            templates,
+		Locale:               loc,
+		Clock:                parser.SystemClock{},
+	})
 	if err != nil {
 		log.Fatalf("Ошибка создания форвардера: %v", err)
 	}
 
-	log.Printf("Параметры приложения: проверяем события на %d дней вперед", cfg.App.DaysAhead)
-	log.Printf("Режим работы: run_once=%v, schedule_cron=%s", cfg.App.RunOnce, cfg.App.ScheduleCron)
+	if len(cfg.App.ReminderStages) > 0 {
+		log.Printf("Параметры приложения: этапы напоминаний за %v дней до события", cfg.App.ReminderStages)
+	}
+	for _, source := range sources {
+		log.Printf("Группа %d %s: события на %d дней вперед, расписание %s, получателей в списке: %d",
+			source.ChatID, source.Name, source.DaysAhead, source.ScheduleCron, len(source.UserIDs))
+	}
+	if cfg.App.SameDayHoursBefore > 0 {
+		log.Printf("Напоминания о событиях со временем: за %d ч. до начала", cfg.App.SameDayHoursBefore)
+	}
+	log.Printf("Режим работы: run_once=%v, mode=%s, parse_mode=%s, locale=%s", cfg.App.RunOnce, cfg.App.Mode, cfg.App.ParseMode, loc.Code)
 
 	// Если флаг -once или конфиг требует однократного запуска
 	if *onceFlag || cfg.App.RunOnce {
-		if err := forwarder.ForwardPinnedMessage(ctx, cfg.Telegram.GroupChatID); err != nil {
-			log.Fatalf("Ошибка при отправке напоминаний: %v", err)
+		failed := false
+		for _, source := range sources {
+			if err := forwarder.ForwardPinnedMessage(ctx, source.ChatID); err != nil {
+				log.Printf("Ошибка при отправке напоминаний из группы %d: %v", source.ChatID, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
 		}
 		return
 	}
 
 	// Запускаем планировщик
 	log.Println("Запуск планировщика...")
-	log.Printf("Расписание: %s", cfg.App.ScheduleCron)
 
 	c := cron.New()
-	_, err = c.AddFunc(cfg.App.ScheduleCron, func() {
-		log.Println("Выполнение запланированной задачи...")
-		if err := forwarder.ForwardPinnedMessage(ctx, cfg.Telegram.GroupChatID); err != nil {
-			log.Printf("Ошибка при отправке напоминаний: %v", err)
-		}
-	})
+	for _, source := range sources {
+		chatID := source.ChatID
+		_, err = c.AddFunc(source.ScheduleCron, func() {
+			log.Printf("Выполнение запланированной задачи для группы %d...", chatID)
+			if err := forwarder.ForwardPinnedMessage(ctx, chatID); err != nil {
+				log.Printf("Ошибка при отправке напоминаний: %v", err)
+			}
+		})
 
-	if err != nil {
-		log.Fatalf("Ошибка при добавлении задачи в планировщик: %v", err)
+		if err != nil {
+			log.Fatalf("Ошибка при добавлении задачи для группы %d в планировщик: %v", chatID, err)
+		}
 	}
 
 	c.Start()
+
+	// Разбираем очередь отправки: повторяем неудачные попытки
+	go forwarder.RunOutboxWorker(ctx, cfg.App.Outbox.PollInterval)
+
+	// Обрабатываем команды пользователей
+	webhookDone := make(chan struct{})
+	if cfg.App.Mode == config.ModeWebhook {
+		go func() {
+			defer close(webhookDone)
+			err := forwarder.RunWebhook(ctx, telegram.WebhookOptions{
+				URL:         cfg.App.Webhook.URL,
+				ListenAddr:  cfg.App.Webhook.ListenAddr,
+				Path:        cfg.App.Webhook.Path,
+				SecretToken: cfg.App.Webhook.SecretToken,
+				CertFile:    cfg.App.Webhook.CertFile,
+				KeyFile:     cfg.App.Webhook.KeyFile,
+			})
+			if err != nil {
+				log.Printf("Ошибка приема обновлений через вебхук: %v", err)
+			}
+		}()
+	} else {
+		close(webhookDone)
+		go forwarder.RunPolling(ctx)
+	}
+
 	log.Println("Приложение запущено. Ожидание запланированных задач...")
 
 	// Обработка сигналов для корректного завершения
@@ -108,5 +278,7 @@ func main() {
 	<-sigChan
 
 	log.Println("Завершение работы...")
-	c.Stop()
+	cancel()
+	<-c.Stop().Done()
+	<-webhookDone
 }
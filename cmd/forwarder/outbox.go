@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+)
+
+// listDeadLetters выводит сообщения, для которых исчерпаны попытки отправки.
+func listDeadLetters(ctx context.Context, repo *database.Repository) error {
+	messages, err := repo.GetDeadLetters(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(messages) == 0 {
+		fmt.Println("Неотправленных сообщений нет")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tПОЛУЧАТЕЛЬ\tПОПЫТОК\tСОЗДАНО\tПОСЛЕДНЯЯ ОШИБКА")
+	for _, msg := range messages {
+		lastError := ""
+		if msg.LastError != nil {
+			lastError = *msg.LastError
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\n",
+			msg.ID, msg.ChatID, msg.Attempts, msg.CreatedAt.Format("2006-01-02 15:04"), lastError)
+	}
+	w.Flush()
+
+	fmt.Println("\nВернуть сообщение в очередь: forwarder -requeue <ID>")
+	return nil
+}
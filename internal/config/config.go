@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -16,9 +17,24 @@ type Config struct {
 }
 
 type TelegramConfig struct {
-	BotToken    string  `mapstructure:"bot_token"`
+	BotToken string `mapstructure:"bot_token"`
+	// GroupChatID группа с закрепленным списком событий, если Sources не задан.
 	GroupChatID int64   `mapstructure:"group_chat_id"`
 	UserIDs     []int64 `mapstructure:"user_ids"`
+	// Sources группы с собственными закрепленными списками событий.
+	Sources []SourceConfig `mapstructure:"sources"`
+}
+
+// SourceConfig группа с закрепленным списком событий. Незаданные days_ahead
+// и schedule_cron берутся из app.
+type SourceConfig struct {
+	ChatID       int64  `mapstructure:"chat_id"`
+	Name         string `mapstructure:"name"`
+	DaysAhead    int    `mapstructure:"days_ahead"`
+	ScheduleCron string `mapstructure:"schedule_cron"`
+	// UserIDs получатели напоминаний из этой группы. Если пусто, напоминания
+	// получают все активные получатели.
+	UserIDs []int64 `mapstructure:"user_ids"`
 }
 
 type DatabaseConfig struct {
@@ -30,11 +46,120 @@ type DatabaseConfig struct {
 	SSLMode  string `mapstructure:"sslmode"`
 }
 
+// Режимы получения обновлений от Telegram.
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// Разметка напоминаний (app.parse_mode).
+const (
+	ParseModePlain      = "plain"
+	ParseModeHTML       = "HTML"
+	ParseModeMarkdownV2 = "MarkdownV2"
+)
+
 type AppConfig struct {
 	RunOnce      bool   `mapstructure:"run_once"`
 	LogLevel     string `mapstructure:"log_level"`
 	DaysAhead    int    `mapstructure:"days_ahead"`
 	ScheduleCron string `mapstructure:"schedule_cron"`
+	// ReminderStages за сколько дней до события напоминать, например [7, 3, 1, 0].
+	// Каждый этап отправляется один раз. Если пусто, используется days_ahead.
+	ReminderStages []int `mapstructure:"reminder_stages"`
+	// SameDayHoursBefore за сколько часов до начала дополнительно напоминать
+	// о событиях с указанным временем. 0 отключает такие напоминания.
+	SameDayHoursBefore int             `mapstructure:"same_day_hours_before"`
+	Outbox             OutboxConfig    `mapstructure:"outbox"`
+	RateLimit          RateLimitConfig `mapstructure:"rate_limit"`
+	// NotifyAdminsOnChange отправлять администраторам группы сводку
+	// при изменении закрепленного списка событий.
+	NotifyAdminsOnChange bool `mapstructure:"notify_admins_on_change"`
+	// SendOnChange сразу запускать рассылку, когда в закрепленном списке
+	// появились новые события.
+	SendOnChange bool `mapstructure:"send_on_change"`
+	// PinHashtag если задан, списками событий считаются только закрепленные
+	// сообщения с этим хэштегом, например "#calendar".
+	PinHashtag string `mapstructure:"pin_hashtag"`
+	// Mode как получать команды от пользователей: polling или webhook.
+	Mode    string        `mapstructure:"mode"`
+	Webhook WebhookConfig `mapstructure:"webhook"`
+	// ParseMode разметка напоминаний: plain (форматирование передается
+	// entities), HTML или MarkdownV2.
+	ParseMode string `mapstructure:"parse_mode"`
+	// Templates шаблоны сообщений text/template.
+	Templates TemplatesConfig `mapstructure:"templates"`
+	// Locale язык сообщений по умолчанию: ru или en.
+	Locale string `mapstructure:"locale"`
+}
+
+// TemplatesConfig шаблоны напоминаний. Шаблон задается текстом или файлом
+// (*_file); файл имеет приоритет. Незаданные шаблоны берутся по умолчанию.
+type TemplatesConfig struct {
+	Header     string `mapstructure:"header"`
+	HeaderFile string `mapstructure:"header_file"`
+	Line       string `mapstructure:"line"`
+	LineFile   string `mapstructure:"line_file"`
+	Footer     string `mapstructure:"footer"`
+	FooterFile string `mapstructure:"footer_file"`
+	Empty      string `mapstructure:"empty"`
+	EmptyFile  string `mapstructure:"empty_file"`
+}
+
+// Load возвращает тексты шаблонов, прочитав заданные файлы.
+func (t TemplatesConfig) Load() (TemplatesConfig, error) {
+	for _, tmpl := range []struct {
+		text *string
+		file string
+	}{
+		{&t.Header, t.HeaderFile},
+		{&t.Line, t.LineFile},
+		{&t.Footer, t.FooterFile},
+		{&t.Empty, t.EmptyFile},
+	} {
+		if tmpl.file == "" {
+			continue
+		}
+		data, err := os.ReadFile(tmpl.file)
+		if err != nil {
+			return t, fmt.Errorf("ошибка чтения файла шаблона: %w", err)
+		}
+		*tmpl.text = strings.TrimRight(string(data), "\n")
+	}
+	return t, nil
+}
+
+// WebhookConfig параметры приема обновлений через вебхук (app.mode: webhook).
+type WebhookConfig struct {
+	// URL внешний адрес сервиса, к нему добавляется Path.
+	URL        string `mapstructure:"url"`
+	ListenAddr string `mapstructure:"listen_addr"`
+	Path       string `mapstructure:"path"`
+	// SecretToken Telegram присылает в заголовке X-Telegram-Bot-Api-Secret-Token.
+	SecretToken string `mapstructure:"secret_token"`
+	// CertFile и KeyFile включают HTTPS, без них сервер работает по HTTP.
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+}
+
+// RateLimitConfig лимиты отправки сообщений в Telegram.
+type RateLimitConfig struct {
+	// MessagesPerSecond общий лимит сообщений в секунду.
+	MessagesPerSecond int `mapstructure:"messages_per_second"`
+	// PerChatInterval минимальный интервал между сообщениями в один чат.
+	PerChatInterval time.Duration `mapstructure:"per_chat_interval"`
+}
+
+// OutboxConfig параметры очереди отправки сообщений.
+type OutboxConfig struct {
+	// MaxAttempts после стольких неудачных попыток сообщение переводится в dead.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// BaseBackoff задержка перед второй попыткой, дальше она удваивается.
+	BaseBackoff time.Duration `mapstructure:"base_backoff"`
+	// MaxBackoff максимальная задержка между попытками.
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// PollInterval как часто проверять очередь в режиме планировщика.
+	PollInterval time.Duration `mapstructure:"poll_interval"`
 }
 
 var cfg *Config
@@ -47,6 +172,20 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("app.log_level", "info")
 	viper.SetDefault("app.days_ahead", 5)
 	viper.SetDefault("app.schedule_cron", "0 8 * * *")
+	viper.SetDefault("app.same_day_hours_before", 0)
+	viper.SetDefault("app.outbox.max_attempts", 5)
+	viper.SetDefault("app.outbox.base_backoff", "30s")
+	viper.SetDefault("app.outbox.max_backoff", "1h")
+	viper.SetDefault("app.outbox.poll_interval", "15s")
+	viper.SetDefault("app.rate_limit.messages_per_second", 30)
+	viper.SetDefault("app.rate_limit.per_chat_interval", "1s")
+	viper.SetDefault("app.notify_admins_on_change", false)
+	viper.SetDefault("app.send_on_change", false)
+	viper.SetDefault("app.mode", ModePolling)
+	viper.SetDefault("app.webhook.listen_addr", ":8080")
+	viper.SetDefault("app.webhook.path", "/telegram/webhook")
+	viper.SetDefault("app.parse_mode", ParseModePlain)
+	viper.SetDefault("app.locale", "ru")
 
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -75,6 +214,30 @@ func LoadConfig() (*Config, error) {
 	viper.BindEnv("app.run_once")
 	viper.BindEnv("app.days_ahead")
 	viper.BindEnv("app.schedule_cron")
+	viper.BindEnv("app.reminder_stages")
+	viper.BindEnv("app.same_day_hours_before")
+	viper.BindEnv("app.outbox.max_attempts")
+	viper.BindEnv("app.outbox.base_backoff")
+	viper.BindEnv("app.outbox.max_backoff")
+	viper.BindEnv("app.outbox.poll_interval")
+	viper.BindEnv("app.rate_limit.messages_per_second")
+	viper.BindEnv("app.rate_limit.per_chat_interval")
+	viper.BindEnv("app.notify_admins_on_change")
+	viper.BindEnv("app.send_on_change")
+	viper.BindEnv("app.pin_hashtag")
+	viper.BindEnv("app.mode")
+	viper.BindEnv("app.webhook.url")
+	viper.BindEnv("app.webhook.listen_addr")
+	viper.BindEnv("app.webhook.path")
+	viper.BindEnv("app.webhook.secret_token")
+	viper.BindEnv("app.webhook.cert_file")
+	viper.BindEnv("app.webhook.key_file")
+	viper.BindEnv("app.parse_mode")
+	viper.BindEnv("app.locale")
+	viper.BindEnv("app.templates.header_file")
+	viper.BindEnv("app.templates.line_file")
+	viper.BindEnv("app.templates.footer_file")
+	viper.BindEnv("app.templates.empty_file")
 
 	cfg = &Config{}
 	if err := viper.Unmarshal(cfg); err != nil {
@@ -116,6 +279,28 @@ func (c *Config) GetDatabaseURL() string {
 	)
 }
 
+// GetSources возвращает группы-источники с подставленными значениями по
+// умолчанию. Если telegram.sources не задан, единственным источником
+// считается telegram.group_chat_id.
+func (c *Config) GetSources() []SourceConfig {
+	sources := c.Telegram.Sources
+	if len(sources) == 0 && c.Telegram.GroupChatID != 0 {
+		sources = []SourceConfig{{ChatID: c.Telegram.GroupChatID}}
+	}
+
+	result := make([]SourceConfig, 0, len(sources))
+	for _, source := range sources {
+		if source.DaysAhead < 1 {
+			source.DaysAhead = c.App.DaysAhead
+		}
+		if source.ScheduleCron == "" {
+			source.ScheduleCron = c.App.ScheduleCron
+		}
+		result = append(result, source)
+	}
+	return result
+}
+
 func GetConfig() *Config {
 	return cfg
 }
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// AdminAction действие администратора группы, выполненное командой боту.
+type AdminAction struct {
+	AdminUserID   int64
+	AdminUsername string
+	ChatID        int64
+	Command       string
+	Arguments     string
+	// Result итог выполнения команды: "ok" или текст ошибки.
+	Result string
+}
+
+// LogAdminAction записывает действие администратора в журнал аудита.
+func (r *Repository) LogAdminAction(ctx context.Context, action AdminAction) error {
+	query := `
+        INSERT INTO admin_audit_log (admin_user_id, admin_username, chat_id, command, arguments, result)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+
+	_, err := r.db.pool.Exec(ctx, query,
+		action.AdminUserID,
+		action.AdminUsername,
+		action.ChatID,
+		action.Command,
+		action.Arguments,
+		action.Result,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в журнал действий администраторов: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Event событие из закрепленного списка, как оно хранится в таблице events.
+type Event struct {
+	ID           int64
+	SourceChatID int64
+	EventHash    string
+	EventDate    time.Time
+	EndDate      time.Time
+	// EventTime время начала в формате "15:04", если оно указано в списке.
+	EventTime       *string
+	Description     string
+	SourceMessageID int
+	FirstSeenAt     time.Time
+	LastSeenAt      time.Time
+	// RemovedAt когда событие пропало из закрепленного списка.
+	RemovedAt *time.Time
+}
+
+// SyncEvents сохраняет события, найденные в закрепленном сообщении, и
+// отмечает удаленными те, что пропали из списка. Прошедшие события,
+// которых больше нет в списке, удаленными не считаются: для событий без
+// года дата просто переходит на следующий год.
+func (r *Repository) SyncEvents(ctx context.Context, sourceChatID int64, events []*Event, today time.Time) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	hashes := make([]string, 0, len(events))
+	for _, event := range events {
+		_, err := tx.Exec(ctx, `
+            INSERT INTO events (source_chat_id, event_hash, event_date, end_date, event_time, description, source_message_id)
+            VALUES ($1, $2, $3, $4, $5::time, $6, $7)
+            ON CONFLICT (source_chat_id, event_hash)
+            DO UPDATE SET
+                end_date = EXCLUDED.end_date,
+                event_time = EXCLUDED.event_time,
+                source_message_id = EXCLUDED.source_message_id,
+                last_seen_at = CURRENT_TIMESTAMP,
+                removed_at = NULL
+        `, sourceChatID, event.EventHash, event.EventDate, event.EndDate, event.EventTime, event.Description, event.SourceMessageID)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения события: %w", err)
+		}
+		hashes = append(hashes, event.EventHash)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE events
+        SET removed_at = CURRENT_TIMESTAMP
+        WHERE source_chat_id = $1
+          AND removed_at IS NULL
+          AND end_date >= $2
+          AND NOT (event_hash = ANY($3::varchar[]))
+    `, sourceChatID, today, hashes)
+	if err != nil {
+		return fmt.Errorf("ошибка отметки удаленных событий: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Статусы сообщений в outbox.
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead"
+)
+
+type OutboxMessage struct {
+	ID          int64
+	ChatID      int64
+	MessageText string
+	// MessageEntities форматирование текста в формате Bot API или nil.
+	MessageEntities []byte
+	MessageLogID    *int64
+	Status          string
+	Attempts        int
+	MaxAttempts     int
+	NextAttemptAt   time.Time
+	LastError       *string
+	SentMessageID   *int
+	SentAt          *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// DeliveryKey этап напоминания, который доставляется сообщением из outbox.
+type DeliveryKey struct {
+	SourceChatID int64
+	EventHash    string
+	Stage        string
+}
+
+const outboxColumns = `
+        id, chat_id, message_text, message_entities, message_log_id, status, attempts, max_attempts,
+        next_attempt_at, last_error, sent_message_id, sent_at, created_at, updated_at
+`
+
+// EnqueueMessage ставит сообщение в очередь отправки и привязывает к нему
+// доставки этапов deliveries получателю chatID.
+func (r *Repository) EnqueueMessage(ctx context.Context, chatID int64, messageText string, messageEntities []byte, messageLogID *int64, maxAttempts int, deliveries []DeliveryKey) (int64, error) {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+        INSERT INTO outbox (chat_id, message_text, message_entities, message_log_id, max_attempts)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id
+    `
+
+	var id int64
+	if err := tx.QueryRow(ctx, query, chatID, messageText, messageEntities, messageLogID, maxAttempts).Scan(&id); err != nil {
+		return 0, fmt.Errorf("ошибка постановки сообщения в очередь: %w", err)
+	}
+
+	for _, key := range deliveries {
+		_, err := tx.Exec(ctx, `
+            UPDATE event_deliveries
+            SET outbox_id = $1,
+                status = 'queued',
+                updated_at = CURRENT_TIMESTAMP
+            WHERE source_chat_id = $2 AND event_hash = $3 AND stage = $4 AND user_id = $5
+        `, id, key.SourceChatID, key.EventHash, key.Stage, chatID)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка привязки доставки к сообщению: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetDueOutboxMessages возвращает сообщения, время очередной попытки которых наступило.
+func (r *Repository) GetDueOutboxMessages(ctx context.Context, limit int) ([]*OutboxMessage, error) {
+	query := `
+        SELECT` + outboxColumns + `
+        FROM outbox
+        WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+        ORDER BY next_attempt_at, id
+        LIMIT $1
+    `
+
+	return r.queryOutbox(ctx, query, limit)
+}
+
+// GetDeadLetters возвращает сообщения, для которых исчерпаны попытки отправки.
+func (r *Repository) GetDeadLetters(ctx context.Context) ([]*OutboxMessage, error) {
+	query := `
+        SELECT` + outboxColumns + `
+        FROM outbox
+        WHERE status = 'dead'
+        ORDER BY updated_at DESC
+    `
+
+	return r.queryOutbox(ctx, query)
+}
+
+// MarkOutboxSent отмечает сообщение отправленным вместе с привязанными к нему
+// доставками, увеличивает счетчик успешных отправок в message_logs и
+// добавляет ID отправленного сообщения в message_logs.sent_message_ids.
+func (r *Repository) MarkOutboxSent(ctx context.Context, id int64, sentMessageID int) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var messageLogID *int64
+	err = tx.QueryRow(ctx, `
+        UPDATE outbox
+        SET status = 'sent',
+            attempts = attempts + 1,
+            last_error = NULL,
+            sent_message_id = $2,
+            sent_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING message_log_id
+    `, id, sentMessageID).Scan(&messageLogID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления сообщения в очереди: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE event_deliveries
+        SET status = 'success',
+            error_message = NULL,
+            attempts = attempts + 1,
+            delivered_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE outbox_id = $1
+    `, id)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления статуса доставки: %w", err)
+	}
+
+	if messageLogID != nil {
+		_, err = tx.Exec(ctx, `
+            UPDATE message_logs
+            SET successfully_sent = successfully_sent + 1,
+                sent_message_ids = array_append(sent_message_ids, $2)
+            WHERE id = $1
+        `, *messageLogID, sentMessageID)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления лога сообщения: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return nil
+}
+
+// MarkOutboxFailed записывает неудачную попытку отправки. Если dead (попытки
+// исчерпаны или повтор не поможет), сообщение переводится в dead, а
+// привязанные доставки — в failed; иначе следующая попытка назначается
+// на nextAttemptAt.
+func (r *Repository) MarkOutboxFailed(ctx context.Context, id int64, errorMsg string, nextAttemptAt time.Time, dead bool) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, deliveryStatus := OutboxStatusPending, DeliveryStatusQueued
+	if dead {
+		status, deliveryStatus = OutboxStatusDead, DeliveryStatusFailed
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE outbox
+        SET attempts = attempts + 1,
+            status = $4,
+            last_error = $2,
+            next_attempt_at = $3,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+    `, id, errorMsg, nextAttemptAt, status)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления сообщения в очереди: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE event_deliveries
+        SET status = $2,
+            error_message = $3,
+            attempts = attempts + 1,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE outbox_id = $1
+    `, id, deliveryStatus, errorMsg)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления статуса доставки: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return nil
+}
+
+// CancelPendingMessages переводит в dead все ожидающие отправки сообщения
+// в чат chatID, например когда получатель заблокировал бота.
+func (r *Repository) CancelPendingMessages(ctx context.Context, chatID int64, reason string) (int64, error) {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+        UPDATE outbox
+        SET status = 'dead',
+            last_error = $2,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE chat_id = $1 AND status = 'pending'
+    `, chatID, reason)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка отмены сообщений в очереди: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE event_deliveries
+        SET status = 'failed',
+            error_message = $2,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND status IN ('pending', 'queued')
+    `, chatID, reason)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка обновления статуса доставки: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// RequeueDeadLetter возвращает сообщение из dead в очередь с обнуленным
+// счетчиком попыток.
+func (r *Repository) RequeueDeadLetter(ctx context.Context, id int64) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+        UPDATE outbox
+        SET status = 'pending',
+            attempts = 0,
+            next_attempt_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND status = 'dead'
+    `, id)
+	if err != nil {
+		return fmt.Errorf("ошибка возврата сообщения в очередь: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("сообщение %d не найдено среди неотправленных", id)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE event_deliveries
+        SET status = 'queued',
+            updated_at = CURRENT_TIMESTAMP
+        WHERE outbox_id = $1
+    `, id)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления статуса доставки: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) queryOutbox(ctx context.Context, query string, args ...interface{}) ([]*OutboxMessage, error) {
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*OutboxMessage
+	for rows.Next() {
+		var msg OutboxMessage
+		err := rows.Scan(
+			&msg.ID,
+			&msg.ChatID,
+			&msg.MessageText,
+			&msg.MessageEntities,
+			&msg.MessageLogID,
+			&msg.Status,
+			&msg.Attempts,
+			&msg.MaxAttempts,
+			&msg.NextAttemptAt,
+			&msg.LastError,
+			&msg.SentMessageID,
+			&msg.SentAt,
+			&msg.CreatedAt,
+			&msg.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		messages = append(messages, &msg)
+	}
+
+	return messages, rows.Err()
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrPinnedSnapshotNotFound = errors.New("снимок закрепленного сообщения не найден")
+
+// PinnedSnapshot последняя известная версия списка событий группы: текст
+// всех закрепленных сообщений со списками событий и ID последнего из них.
+type PinnedSnapshot struct {
+	ChatID      int64
+	MessageID   int
+	MessageText string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (r *Repository) GetPinnedSnapshot(ctx context.Context, chatID int64) (*PinnedSnapshot, error) {
+	query := `
+        SELECT chat_id, message_id, message_text, created_at, updated_at
+        FROM pinned_snapshots
+        WHERE chat_id = $1
+    `
+
+	var snapshot PinnedSnapshot
+	err := r.db.pool.QueryRow(ctx, query, chatID).Scan(
+		&snapshot.ChatID,
+		&snapshot.MessageID,
+		&snapshot.MessageText,
+		&snapshot.CreatedAt,
+		&snapshot.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("chat_id %d: %w", chatID, ErrPinnedSnapshotNotFound)
+		}
+		return nil, fmt.Errorf("ошибка получения снимка закрепленного сообщения: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// SavePinnedSnapshot сохраняет текущую версию списка событий группы.
+func (r *Repository) SavePinnedSnapshot(ctx context.Context, chatID int64, messageID int, messageText string) error {
+	query := `
+        INSERT INTO pinned_snapshots (chat_id, message_id, message_text)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (chat_id)
+        DO UPDATE SET
+            message_id = EXCLUDED.message_id,
+            message_text = EXCLUDED.message_text,
+            updated_at = CURRENT_TIMESTAMP
+    `
+
+	_, err := r.db.pool.Exec(ctx, query, chatID, messageID, messageText)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения снимка закрепленного сообщения: %w", err)
+	}
+
+	return nil
+}
+
+// PinnedMessage закрепленное сообщение группы. Telegram не сообщает боту
+// об откреплении, поэтому сообщение остается в списке, пока его не удалят
+// командой /forgetpin.
+type PinnedMessage struct {
+	ChatID      int64
+	MessageID   int
+	MessageText string
+	// MessageEntities форматирование текста в формате Bot API (JSON-массив
+	// MessageEntity) или nil.
+	MessageEntities []byte
+	PinnedAt        time.Time
+	UpdatedAt       time.Time
+}
+
+// SavePinnedMessage сохраняет сообщение как последнее закрепленное в группе.
+// Повторно закрепленное сообщение переносится в конец списка.
+func (r *Repository) SavePinnedMessage(ctx context.Context, chatID int64, messageID int, messageText string, messageEntities []byte) error {
+	query := `
+        INSERT INTO pinned_messages (chat_id, message_id, message_text, message_entities)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (chat_id, message_id)
+        DO UPDATE SET
+            message_text = EXCLUDED.message_text,
+            message_entities = EXCLUDED.message_entities,
+            pinned_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+    `
+
+	_, err := r.db.pool.Exec(ctx, query, chatID, messageID, messageText, messageEntities)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения закрепленного сообщения: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePinnedMessageText обновляет текст и форматирование сообщения, если оно
+// есть среди закрепленных. Возвращает false, если сообщение не закреплено.
+func (r *Repository) UpdatePinnedMessageText(ctx context.Context, chatID int64, messageID int, messageText string, messageEntities []byte) (bool, error) {
+	query := `
+        UPDATE pinned_messages
+        SET message_text = $3,
+            message_entities = $4,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE chat_id = $1 AND message_id = $2
+    `
+
+	tag, err := r.db.pool.Exec(ctx, query, chatID, messageID, messageText, messageEntities)
+	if err != nil {
+		return false, fmt.Errorf("ошибка обновления закрепленного сообщения: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetPinnedMessages возвращает закрепленные сообщения группы в порядке закрепления.
+func (r *Repository) GetPinnedMessages(ctx context.Context, chatID int64) ([]*PinnedMessage, error) {
+	query := `
+        SELECT chat_id, message_id, message_text, message_entities, pinned_at, updated_at
+        FROM pinned_messages
+        WHERE chat_id = $1
+        ORDER BY pinned_at, message_id
+    `
+
+	rows, err := r.db.pool.Query(ctx, query, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения закрепленных сообщений: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*PinnedMessage
+	for rows.Next() {
+		var msg PinnedMessage
+		if err := rows.Scan(&msg.ChatID, &msg.MessageID, &msg.MessageText, &msg.MessageEntities, &msg.PinnedAt, &msg.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		messages = append(messages, &msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// DeletePinnedMessage убирает сообщение из закрепленных. Возвращает false,
+// если такого сообщения среди закрепленных нет.
+func (r *Repository) DeletePinnedMessage(ctx context.Context, chatID int64, messageID int) (bool, error) {
+	query := `
+        DELETE FROM pinned_messages
+        WHERE chat_id = $1 AND message_id = $2
+    `
+
+	tag, err := r.db.pool.Exec(ctx, query, chatID, messageID)
+	if err != nil {
+		return false, fmt.Errorf("ошибка удаления закрепленного сообщения: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeletePinnedMessages убирает все закрепленные сообщения группы и
+// возвращает, сколько их было.
+func (r *Repository) DeletePinnedMessages(ctx context.Context, chatID int64) (int64, error) {
+	query := `
+        DELETE FROM pinned_messages
+        WHERE chat_id = $1
+    `
+
+	tag, err := r.db.pool.Exec(ctx, query, chatID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления закрепленных сообщений: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
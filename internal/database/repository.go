@@ -4,12 +4,24 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 )
 
+var ErrRecipientNotFound = errors.New("получатель не найден")
+
+// Статусы доставки в event_deliveries и сводный статус в recipients.
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusQueued  = "queued"
+	DeliveryStatusSuccess = "success"
+	DeliveryStatusFailed  = "failed"
+)
+
 type Recipient struct {
 	ID             int64
 	UserID         int64
@@ -19,8 +31,14 @@ type Recipient struct {
 	LastSentAt     *time.Time
 	DeliveryStatus string
 	ErrorMessage   *string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	// DeactivationReason почему получатель был автоматически отключен
+	// (например, заблокировал бота).
+	DeactivationReason *string
+	DeactivatedAt      *time.Time
+	// Language код языка сообщений; nil — язык по умолчанию из конфигурации.
+	Language  *string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 type MessageLog struct {
@@ -41,10 +59,38 @@ func NewRepository(db *Database) *Repository {
 	return &Repository{db: db}
 }
 
+const recipientColumns = `
+        id, user_id, username, is_active, allow_sending, last_sent_at,
+        delivery_status, error_message, deactivation_reason, deactivated_at,
+        language, created_at, updated_at
+`
+
+func scanRecipient(row pgx.Row) (*Recipient, error) {
+	var recipient Recipient
+	err := row.Scan(
+		&recipient.ID,
+		&recipient.UserID,
+		&recipient.Username,
+		&recipient.IsActive,
+		&recipient.AllowSending,
+		&recipient.LastSentAt,
+		&recipient.DeliveryStatus,
+		&recipient.ErrorMessage,
+		&recipient.DeactivationReason,
+		&recipient.DeactivatedAt,
+		&recipient.Language,
+		&recipient.CreatedAt,
+		&recipient.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &recipient, nil
+}
+
 func (r *Repository) GetActiveRecipients(ctx context.Context) ([]*Recipient, error) {
 	query := `
-        SELECT id, user_id, username, is_active, allow_sending, last_sent_at, 
-               delivery_status, error_message, created_at, updated_at
+        SELECT` + recipientColumns + `
         FROM recipients
         WHERE is_active = true AND allow_sending = true
         ORDER BY user_id
@@ -58,35 +104,49 @@ func (r *Repository) GetActiveRecipients(ctx context.Context) ([]*Recipient, err
 
 	var recipients []*Recipient
 	for rows.Next() {
-		var recipient Recipient
-		err := rows.Scan(
-			&recipient.ID,
-			&recipient.UserID,
-			&recipient.Username,
-			&recipient.IsActive,
-			&recipient.AllowSending,
-			&recipient.LastSentAt,
-			&recipient.DeliveryStatus,
-			&recipient.ErrorMessage,
-			&recipient.CreatedAt,
-			&recipient.UpdatedAt,
-		)
+		recipient, err := scanRecipient(rows)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
 		}
-		recipients = append(recipients, &recipient)
+		recipients = append(recipients, recipient)
 	}
 
 	return recipients, nil
 }
 
+// GetAllRecipients возвращает всех получателей, включая отключенных.
+func (r *Repository) GetAllRecipients(ctx context.Context) ([]*Recipient, error) {
+	query := `
+        SELECT` + recipientColumns + `
+        FROM recipients
+        ORDER BY user_id
+    `
+
+	rows, err := r.db.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var recipients []*Recipient
+	for rows.Next() {
+		recipient, err := scanRecipient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, rows.Err()
+}
+
 func (r *Repository) UpsertRecipient(ctx context.Context, userID int64, username string) error {
 	query := `
         INSERT INTO recipients (user_id, username, is_active, allow_sending, delivery_status)
         VALUES ($1, $2, true, true, 'pending')
         ON CONFLICT (user_id)
         DO UPDATE SET
-            username = EXCLUDED.username,
+            username = COALESCE(NULLIF(EXCLUDED.username, ''), recipients.username),
             updated_at = CURRENT_TIMESTAMP
     `
 
@@ -98,17 +158,29 @@ func (r *Repository) UpsertRecipient(ctx context.Context, userID int64, username
 	return nil
 }
 
-func (r *Repository) UpdateDeliveryStatus(ctx context.Context, userID int64, status string, errorMsg *string) error {
+// RefreshDeliveryStatus пересчитывает сводку по доставке в recipients из
+// event_deliveries: статус и ошибка берутся из последней попытки доставки.
+func (r *Repository) RefreshDeliveryStatus(ctx context.Context, userID int64) error {
 	query := `
+        WITH last_delivery AS (
+            SELECT status, error_message
+            FROM event_deliveries
+            WHERE user_id = $1 AND status NOT IN ('pending', 'queued')
+            ORDER BY updated_at DESC, id DESC
+            LIMIT 1
+        )
         UPDATE recipients
-        SET delivery_status = $1,
-            error_message = $2,
-            last_sent_at = CURRENT_TIMESTAMP,
+        SET delivery_status = COALESCE((SELECT status FROM last_delivery), delivery_status),
+            error_message = (SELECT error_message FROM last_delivery),
+            last_sent_at = COALESCE(
+                (SELECT MAX(delivered_at) FROM event_deliveries WHERE user_id = $1),
+                last_sent_at
+            ),
             updated_at = CURRENT_TIMESTAMP
-        WHERE user_id = $3
+        WHERE user_id = $1
     `
 
-	_, err := r.db.pool.Exec(ctx, query, status, errorMsg, userID)
+	_, err := r.db.pool.Exec(ctx, query, userID)
 	if err != nil {
 		return fmt.Errorf("ошибка обновления статуса доставки: %w", err)
 	}
@@ -116,61 +188,73 @@ func (r *Repository) UpdateDeliveryStatus(ctx context.Context, userID int64, sta
 	return nil
 }
 
-func (r *Repository) CreateMessageLog(ctx context.Context, messageID int, messageType, messageText string, totalRecipients, successfullySent int) error {
+func (r *Repository) CreateMessageLog(ctx context.Context, sourceChatID int64, messageID int, messageType, messageText string, totalRecipients, successfullySent int) (int64, error) {
 	query := `
-        INSERT INTO message_logs (message_id, message_type, message_text, total_recipients, successfully_sent)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO message_logs (source_chat_id, message_id, message_type, message_text, total_recipients, successfully_sent)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
     `
 
-	_, err := r.db.pool.Exec(ctx, query, messageID, messageType, messageText, totalRecipients, successfullySent)
+	var id int64
+	err := r.db.pool.QueryRow(ctx, query, sourceChatID, messageID, messageType, messageText, totalRecipients, successfullySent).Scan(&id)
 	if err != nil {
-		return fmt.Errorf("ошибка создания лога сообщения: %w", err)
+		return 0, fmt.Errorf("ошибка создания лога сообщения: %w", err)
 	}
 
-	return nil
+	return id, nil
 }
 
 func (r *Repository) GetRecipientByUserID(ctx context.Context, userID int64) (*Recipient, error) {
 	query := `
-        SELECT id, user_id, username, is_active, allow_sending, last_sent_at, 
-               delivery_status, error_message, created_at, updated_at
+        SELECT` + recipientColumns + `
         FROM recipients
         WHERE user_id = $1
     `
 
-	var recipient Recipient
-	err := r.db.pool.QueryRow(ctx, query, userID).Scan(
-		&recipient.ID,
-		&recipient.UserID,
-		&recipient.Username,
-		&recipient.IsActive,
-		&recipient.AllowSending,
-		&recipient.LastSentAt,
-		&recipient.DeliveryStatus,
-		&recipient.ErrorMessage,
-		&recipient.CreatedAt,
-		&recipient.UpdatedAt,
-	)
+	recipient, err := scanRecipient(r.db.pool.QueryRow(ctx, query, userID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("user_id %d: %w", userID, ErrRecipientNotFound)
+		}
+		return nil, fmt.Errorf("ошибка получения получателя: %w", err)
+	}
 
+	return recipient, nil
+}
+
+// GetRecipientByUsername ищет получателя по username без учета регистра.
+func (r *Repository) GetRecipientByUsername(ctx context.Context, username string) (*Recipient, error) {
+	query := `
+        SELECT` + recipientColumns + `
+        FROM recipients
+        WHERE lower(username) = lower($1)
+        ORDER BY user_id
+        LIMIT 1
+    `
+
+	recipient, err := scanRecipient(r.db.pool.QueryRow(ctx, query, username))
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("получатель с user_id %d не найден", userID)
+			return nil, fmt.Errorf("username %s: %w", username, ErrRecipientNotFound)
 		}
 		return nil, fmt.Errorf("ошибка получения получателя: %w", err)
 	}
 
-	return &recipient, nil
+	return recipient, nil
 }
 
-func (r *Repository) DeactivateRecipient(ctx context.Context, userID int64) error {
+// DeactivateRecipient отключает получателя и сохраняет причину отключения.
+func (r *Repository) DeactivateRecipient(ctx context.Context, userID int64, reason string) error {
 	query := `
         UPDATE recipients
         SET is_active = false,
+            deactivation_reason = $2,
+            deactivated_at = CURRENT_TIMESTAMP,
             updated_at = CURRENT_TIMESTAMP
         WHERE user_id = $1
     `
 
-	_, err := r.db.pool.Exec(ctx, query, userID)
+	_, err := r.db.pool.Exec(ctx, query, userID, reason)
 	if err != nil {
 		return fmt.Errorf("ошибка деактивации получателя: %w", err)
 	}
@@ -178,6 +262,26 @@ func (r *Repository) DeactivateRecipient(ctx context.Context, userID int64) erro
 	return nil
 }
 
+// ReactivateRecipient снова включает получателя, отключенного автоматически
+// или вручную, например после того как он сам написал боту /start.
+func (r *Repository) ReactivateRecipient(ctx context.Context, userID int64) error {
+	query := `
+        UPDATE recipients
+        SET is_active = true,
+            deactivation_reason = NULL,
+            deactivated_at = NULL,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1
+    `
+
+	_, err := r.db.pool.Exec(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка повторной активации получателя: %w", err)
+	}
+
+	return nil
+}
+
 func (r *Repository) SetAllowSending(ctx context.Context, userID int64, allowSending bool) error {
 	query := `
         UPDATE recipients
@@ -194,13 +298,54 @@ func (r *Repository) SetAllowSending(ctx context.Context, userID int64, allowSen
 	return nil
 }
 
-func (r *Repository) IsEventSent(ctx context.Context, eventHash string) (bool, error) {
+// SetRecipientLanguage задает язык сообщений получателя.
+func (r *Repository) SetRecipientLanguage(ctx context.Context, userID int64, language string) error {
+	query := `
+        UPDATE recipients
+        SET language = $1,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $2
+    `
+
+	_, err := r.db.pool.Exec(ctx, query, language, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления языка получателя: %w", err)
+	}
+
+	return nil
+}
+
+// SetRecipientLanguageIfEmpty задает язык, если получатель его еще не выбрал,
+// например по language_code из Telegram.
+func (r *Repository) SetRecipientLanguageIfEmpty(ctx context.Context, userID int64, language string) error {
+	query := `
+        UPDATE recipients
+        SET language = $1,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $2 AND language IS NULL
+    `
+
+	_, err := r.db.pool.Exec(ctx, query, language, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления языка получателя: %w", err)
+	}
+
+	return nil
+}
+
+// IsEventSent проверяет, отправлялось ли напоминание о событии из группы
+// sourceChatID на этапе stage. Пустой stage соответствует обычному
+// напоминанию за days_ahead дней.
+func (r *Repository) IsEventSent(ctx context.Context, sourceChatID int64, eventHash, stage string) (bool, error) {
 	query := `
-        SELECT EXISTS(SELECT 1 FROM sent_events WHERE event_hash = $1)
+        SELECT EXISTS(
+            SELECT 1 FROM sent_events
+            WHERE source_chat_id = $1 AND event_hash = $2 AND stage = $3
+        )
     `
 
 	var exists bool
-	err := r.db.pool.QueryRow(ctx, query, eventHash).Scan(&exists)
+	err := r.db.pool.QueryRow(ctx, query, sourceChatID, eventHash, stage).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("ошибка проверки отправленного события: %w", err)
 	}
@@ -208,16 +353,96 @@ func (r *Repository) IsEventSent(ctx context.Context, eventHash string) (bool, e
 	return exists, nil
 }
 
-func (r *Repository) MarkEventAsSent(ctx context.Context, eventDate time.Time, eventDescription, eventHash string) error {
+// MarkEventAsSent отмечает этап stage события отправленным и создает
+// ожидающие доставки для получателей userIDs в одной транзакции, чтобы этап
+// не оказался отмеченным без записей о доставке.
+func (r *Repository) MarkEventAsSent(ctx context.Context, sourceChatID int64, eventDate time.Time, eventDescription, eventHash, stage string, userIDs []int64) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO sent_events (source_chat_id, event_date, event_description, event_hash, stage, sent_at)
+        VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+        ON CONFLICT (source_chat_id, event_hash, stage) DO NOTHING
+    `, sourceChatID, eventDate, eventDescription, eventHash, stage)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения отправленного события: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO event_deliveries (source_chat_id, event_hash, stage, user_id, status)
+        SELECT $1, $2, $3, user_id, 'pending'
+        FROM unnest($4::bigint[]) AS user_id
+        ON CONFLICT (source_chat_id, event_hash, stage, user_id) DO NOTHING
+    `, sourceChatID, eventHash, stage, userIDs)
+	if err != nil {
+		return fmt.Errorf("ошибка создания записей о доставке: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return nil
+}
+
+// GetUndeliveredUserIDs возвращает активных получателей, для которых этап stage
+// был запланирован, но так и не поставлен в очередь отправки. Повторные
+// попытки для уже поставленных в очередь сообщений выполняет outbox.
+func (r *Repository) GetUndeliveredUserIDs(ctx context.Context, sourceChatID int64, eventHash, stage string) ([]int64, error) {
 	query := `
-        INSERT INTO sent_events (event_date, event_description, event_hash, sent_at)
-        VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
-        ON CONFLICT (event_hash) DO NOTHING
+        SELECT d.user_id
+        FROM event_deliveries d
+        JOIN recipients r ON r.user_id = d.user_id
+        WHERE d.source_chat_id = $1 AND d.event_hash = $2 AND d.stage = $3 AND d.status = 'pending'
+          AND r.is_active = true AND r.allow_sending = true
+        ORDER BY d.user_id
     `
 
-	_, err := r.db.pool.Exec(ctx, query, eventDate, eventDescription, eventHash)
+	rows, err := r.db.pool.Query(ctx, query, sourceChatID, eventHash, stage)
 	if err != nil {
-		return fmt.Errorf("ошибка сохранения отправленного события: %w", err)
+		return nil, fmt.Errorf("ошибка получения недоставленных напоминаний: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// AdoptLegacySourceRows привязывает к группе sourceChatID записи, созданные
+// до поддержки нескольких групп (source_chat_id = 0), чтобы уже отправленные
+// напоминания не ушли повторно.
+func (r *Repository) AdoptLegacySourceRows(ctx context.Context, sourceChatID int64) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"sent_events", "message_logs", "event_deliveries", "events"} {
+		query := `UPDATE ` + table + ` SET source_chat_id = $1 WHERE source_chat_id = 0`
+		tag, err := tx.Exec(ctx, query, sourceChatID)
+		if err != nil {
+			return fmt.Errorf("ошибка привязки записей %s к группе: %w", table, err)
+		}
+		if tag.RowsAffected() > 0 {
+			log.Printf("Записи %s привязаны к группе %d: %d", table, sourceChatID, tag.RowsAffected())
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,185 @@
+package locale
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default язык сообщений, если в конфигурации не указан другой.
+const Default = "ru"
+
+// Locale правила вывода дат, дней недели и относительных сроков на одном языке.
+type Locale struct {
+	// Code код языка: "ru", "en".
+	Code string
+	// months названия месяцев в форме, которая стоит после числа:
+	// для русского родительный падеж ("5 марта").
+	months [12]string
+	// monthAliases другие написания месяцев, которые распознаются в списке
+	// событий: именительный падеж, сокращения.
+	monthAliases [12][]string
+	// weekdays названия дней недели, начиная с воскресенья, как в time.Weekday.
+	weekdays [7]string
+	today    string
+	tomorrow string
+	// inDays шаблон "через N дней", получает число и склоненное слово "день".
+	inDays string
+	// dayForms формы слова "день" для plural.
+	dayForms []string
+	// plural выбирает номер формы слова для числа n.
+	plural func(n int) int
+	// startsIn и endsIn статусы многодневного события, получают относительный срок.
+	startsIn string
+	endsIn   string
+	// messages каталог сообщений бота из messages/<код>.json.
+	messages map[string]string
+}
+
+// Russian русский язык.
+var Russian = &Locale{
+	Code: "ru",
+	months: [12]string{
+		"января", "февраля", "марта", "апреля", "мая", "июня",
+		"июля", "августа", "сентября", "октября", "ноября", "декабря",
+	},
+	monthAliases: [12][]string{
+		{"январь", "янв"},
+		{"февраль", "фев", "февр"},
+		{"март", "мар"},
+		{"апрель", "апр"},
+		{"май"},
+		{"июнь", "июн"},
+		{"июль", "июл"},
+		{"август", "авг"},
+		{"сентябрь", "сен", "сент"},
+		{"октябрь", "окт"},
+		{"ноябрь", "ноя", "нояб"},
+		{"декабрь", "дек"},
+	},
+	weekdays: [7]string{
+		"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота",
+	},
+	today:    "сегодня",
+	tomorrow: "завтра",
+	inDays:   "через %d %s",
+	dayForms: []string{"день", "дня", "дней"},
+	plural:   russianPlural,
+	startsIn: "начнется %s",
+	endsIn:   "идет сейчас, закончится %s",
+}
+
+// English английский язык.
+var English = &Locale{
+	Code: "en",
+	months: [12]string{
+		"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December",
+	},
+	monthAliases: [12][]string{
+		{"Jan"}, {"Feb"}, {"Mar"}, {"Apr"}, {}, {"Jun"},
+		{"Jul"}, {"Aug"}, {"Sep", "Sept"}, {"Oct"}, {"Nov"}, {"Dec"},
+	},
+	weekdays: [7]string{
+		"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday",
+	},
+	today:    "today",
+	tomorrow: "tomorrow",
+	inDays:   "in %d %s",
+	dayForms: []string{"day", "days"},
+	plural:   englishPlural,
+	startsIn: "starts %s",
+	endsIn:   "in progress, ends %s",
+}
+
+var locales = map[string]*Locale{
+	Russian.Code: Russian,
+	English.Code: English,
+}
+
+// Get возвращает язык по коду.
+func Get(code string) (*Locale, bool) {
+	loc, ok := locales[code]
+	return loc, ok
+}
+
+// Codes коды поддерживаемых языков по алфавиту.
+func Codes() []string {
+	codes := make([]string, 0, len(locales))
+	for code := range locales {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// russianPlural форма для 1, 21 ("день"), 2–4, 22 ("дня") и остальных ("дней").
+func russianPlural(n int) int {
+	if n < 0 {
+		n = -n
+	}
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return 0
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 10 || n%100 >= 20):
+		return 1
+	}
+	return 2
+}
+
+func englishPlural(n int) int {
+	if n == 1 || n == -1 {
+		return 0
+	}
+	return 1
+}
+
+// FormatDate день и месяц: "5 марта", "5 March".
+func (l *Locale) FormatDate(t time.Time) string {
+	return fmt.Sprintf("%d %s", t.Day(), l.months[t.Month()-1])
+}
+
+// MonthNames все написания месяцев языка в нижнем регистре и номера месяцев
+// от 1 до 12: форма из FormatDate и ее варианты.
+func (l *Locale) MonthNames() map[string]int {
+	names := make(map[string]int)
+	for i, name := range l.months {
+		names[strings.ToLower(name)] = i + 1
+		for _, alias := range l.monthAliases[i] {
+			names[strings.ToLower(alias)] = i + 1
+		}
+	}
+	return names
+}
+
+// Weekday название дня недели.
+func (l *Locale) Weekday(t time.Time) string {
+	return l.weekdays[t.Weekday()]
+}
+
+// PluralDays слово "день" в форме для числа n.
+func (l *Locale) PluralDays(n int) string {
+	return l.dayForms[l.plural(n)]
+}
+
+// RelativeDays срок через days дней: "сегодня", "завтра", "через 3 дня".
+func (l *Locale) RelativeDays(days int) string {
+	switch days {
+	case 0:
+		return l.today
+	case 1:
+		return l.tomorrow
+	}
+	return fmt.Sprintf(l.inDays, days, l.PluralDays(days))
+}
+
+// StartsIn статус события, которое начнется через days дней.
+func (l *Locale) StartsIn(days int) string {
+	return fmt.Sprintf(l.startsIn, l.RelativeDays(days))
+}
+
+// EndsIn статус идущего события, которое закончится через days дней.
+func (l *Locale) EndsIn(days int) string {
+	return fmt.Sprintf(l.endsIn, l.RelativeDays(days))
+}
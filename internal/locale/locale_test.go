@@ -0,0 +1,90 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRelativeDays(t *testing.T) {
+	tests := []struct {
+		loc  *Locale
+		days int
+		want string
+	}{
+		{Russian, 0, "сегодня"},
+		{Russian, 1, "завтра"},
+		{Russian, 2, "через 2 дня"},
+		{Russian, 5, "через 5 дней"},
+		{Russian, 11, "через 11 дней"},
+		{Russian, 21, "через 21 день"},
+		{Russian, 22, "через 22 дня"},
+		{English, 0, "today"},
+		{English, 1, "tomorrow"},
+		{English, 3, "in 3 days"},
+		{English, 21, "in 21 days"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.loc.RelativeDays(tt.days); got != tt.want {
+			t.Errorf("%s: RelativeDays(%d) = %q, ожидалось %q", tt.loc.Code, tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	day := time.Date(2026, time.March, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		loc         *Locale
+		wantDate    string
+		wantWeekday string
+	}{
+		{Russian, "5 марта", "четверг"},
+		{English, "5 March", "Thursday"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.loc.FormatDate(day); got != tt.wantDate {
+			t.Errorf("%s: FormatDate = %q, ожидалось %q", tt.loc.Code, got, tt.wantDate)
+		}
+		if got := tt.loc.Weekday(day); got != tt.wantWeekday {
+			t.Errorf("%s: Weekday = %q, ожидалось %q", tt.loc.Code, got, tt.wantWeekday)
+		}
+	}
+}
+
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	for _, code := range Codes() {
+		loc, _ := Get(code)
+		for key := range Russian.messages {
+			if _, ok := loc.messages[key]; !ok {
+				t.Errorf("в каталоге %s нет сообщения %s", code, key)
+			}
+		}
+		for key := range loc.messages {
+			if _, ok := Russian.messages[key]; !ok {
+				t.Errorf("сообщение %s каталога %s отсутствует в русском каталоге", key, code)
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		code   string
+		want   string
+		wantOK bool
+	}{
+		{"ru", "ru", true},
+		{"en-US", "en", true},
+		{"EN", "en", true},
+		{"de", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		loc, ok := Match(tt.code)
+		if ok != tt.wantOK || (ok && loc.Code != tt.want) {
+			t.Errorf("Match(%q) = %v, %v, ожидалось %q, %v", tt.code, loc, ok, tt.want, tt.wantOK)
+		}
+	}
+}
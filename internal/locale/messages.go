@@ -0,0 +1,50 @@
+package locale
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:embed messages/*.json
+var messageFiles embed.FS
+
+func init() {
+	for code, loc := range locales {
+		data, err := messageFiles.ReadFile("messages/" + code + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("нет каталога сообщений для языка %s: %v", code, err))
+		}
+		if err := json.Unmarshal(data, &loc.messages); err != nil {
+			panic(fmt.Sprintf("ошибка разбора каталога сообщений %s: %v", code, err))
+		}
+	}
+}
+
+// T возвращает сообщение key из каталога языка, подставив args как в
+// fmt.Sprintf. Если сообщения нет, оно берется из русского каталога,
+// а если нет и там — возвращается сам ключ.
+func (l *Locale) T(key string, args ...interface{}) string {
+	format, ok := l.messages[key]
+	if !ok {
+		if format, ok = Russian.messages[key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Name название языка на нем самом.
+func (l *Locale) Name() string {
+	return l.T("language.name")
+}
+
+// Match подбирает язык по коду языка пользователя Telegram, например "en-US".
+func Match(languageCode string) (*Locale, bool) {
+	code, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	return Get(code)
+}
@@ -0,0 +1,29 @@
+package parser
+
+import "time"
+
+// Clock источник текущего времени. Позволяет подменять время в тестах
+// и воспроизводить ошибки на границе года.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock возвращает системное время.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock всегда возвращает одно и то же время.
+type FixedClock struct {
+	Time time.Time
+}
+
+func (c FixedClock) Now() time.Time {
+	return c.Time
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -1,198 +1,529 @@
 package parser
 
 import (
-    "fmt"
-    "regexp"
-    "strconv"
-    "strings"
-    "time"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
 )
 
 type EventEntry struct {
-    Date        time.Time
-    Description string
-    RawDate     string
-    IsValid     bool
-}
-
-var monthNames = map[string]int{
-    "января":   1,
-    "февраля":  2,
-    "марта":    3,
-    "апреля":   4,
-    "мая":      5,
-    "июня":     6,
-    "июля":     7,
-    "августа":  8,
-    "сентября": 9,
-    "октября":  10,
-    "ноября":   11,
-    "декабря":  12,
-}
-
-func ParseEventList(text string) []*EventEntry {
-    lines := strings.Split(text, "\n")
-    var events []*EventEntry
-
-    for _, line := range lines {
-        line = strings.TrimSpace(line)
-        if line == "" {
-            continue
-        }
+	Date time.Time
+	// EndDate последний день события. Для однодневных событий совпадает с Date.
+	EndDate     time.Time
+	Description string
+	RawDate     string
+	IsValid     bool
+	// HasYear год указан в строке явно и не подбирается автоматически.
+	HasYear bool
+	// Expired дата с явным годом уже прошла.
+	Expired bool
+	// HasTime в строке указано время начала, оно хранится в Date.
+	HasTime bool
+	// LeadTimes за сколько дней напоминать о событии, по убыванию. Задается
+	// в строке отметками "(за 7 дней)" или "[remind: 7d,1d]"; если пусто,
+	// используется общий days_ahead.
+	LeadTimes []int
+	// DescriptionEntities форматирование описания (жирный текст, ссылки,
+	// упоминания) со смещениями относительно начала Description.
+	DescriptionEntities []Entity
+}
 
-        event := parseEventLine(line)
-        if event != nil {
-            events = append(events, event)
-        }
-    }
+// IsRange событие длится несколько дней.
+func (e *EventEntry) IsRange() bool {
+	return !e.EndDate.Equal(e.Date)
+}
 
-    return events
+// monthNames написания месяцев всех языков из пакета locale в нижнем
+// регистре. Даты вида "5 марта" распознаются на любом поддерживаемом языке
+// независимо от языка вывода.
+var monthNames = allMonthNames()
+
+func allMonthNames() map[string]int {
+	names := make(map[string]int)
+	for _, code := range locale.Codes() {
+		loc, _ := locale.Get(code)
+		for name, month := range loc.MonthNames() {
+			names[name] = month
+		}
+	}
+	return names
 }
 
-func parseEventLine(line string) *EventEntry {
-    entry := &EventEntry{
-        RawDate:     line,
-        IsValid:     false,
-    }
+// monthPattern альтернатива из названий месяцев для регулярного выражения.
+// Длинные названия идут первыми, чтобы "марта" не совпало как "мар".
+func monthPattern(names map[string]int) string {
+	alternatives := make([]string, 0, len(names))
+	for name := range names {
+		alternatives = append(alternatives, regexp.QuoteMeta(name))
+	}
+	sort.Slice(alternatives, func(i, j int) bool {
+		if len(alternatives[i]) != len(alternatives[j]) {
+			return len(alternatives[i]) > len(alternatives[j])
+		}
+		return alternatives[i] < alternatives[j]
+	})
+	return strings.Join(alternatives, "|")
+}
 
-    var date time.Time
-    var description string
-    var found bool
+var (
+	isoDateRe    = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})`)
+	dotDateRe    = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})(?:\.(\d{4}|\d{2}))?`)
+	monthDateRe  = regexp.MustCompile(`(?i)^(\d{1,2})\s+(` + monthPattern(monthNames) + `)\.?`)
+	monthYearRe  = regexp.MustCompile(`^\s+(\d{4})\b`)
+	rangeStartRe = regexp.MustCompile(`^(\d{1,2})\s*[-–—]\s*`)
+	rangeSepRe   = regexp.MustCompile(`^\s*[-–—]\s*`)
+	timeOfDayRe  = regexp.MustCompile(`^\s+(?:в\s+)?(\d{1,2})[:.](\d{2})`)
+)
 
-    date, description, found = tryParseRussianFormat(line)
-    if !found {
-        date, description, found = tryParseDotFormat(line)
-    }
-    if !found {
-        date, description, found = tryParseRangeFormat(line)
-    }
+// Четыре цифры после названия месяца считаются годом, только если он между
+// minYear и maxYear. Другое число остается в описании: "5 марта 1500 участников".
+const (
+	minYear = 2000
+	maxYear = 2099
+)
+
+// dateSpec дата, как она записана в строке. year равен 0, если год не указан.
+type dateSpec struct {
+	day   int
+	month int
+	year  int
+}
 
-    if found {
-        entry.Date = date
-        entry.Description = description
-        entry.IsValid = true
-    }
+// Parser разбирает список событий относительно времени, которое возвращает clock,
+// и выводит даты на языке loc.
+type Parser struct {
+	clock Clock
+	loc   *locale.Locale
+}
 
-    return entry
+func NewParser(clock Clock) *Parser {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &Parser{clock: clock, loc: locale.Russian}
 }
 
-func tryParseRussianFormat(line string) (time.Time, string, bool) {
-    re := regexp.MustCompile(`^(\d{1,2})\s+(января|февраля|марта|апреля|мая|июня|июля|августа|сентября|октября|ноября|декабря)\s+(.*)$`)
-    matches := re.FindStringSubmatch(line)
+// WithLocale возвращает парсер, который выводит даты на языке loc.
+func (p *Parser) WithLocale(loc *locale.Locale) *Parser {
+	localized := *p
+	localized.loc = loc
+	return &localized
+}
 
-    if len(matches) < 4 {
-        return time.Time{}, "", false
-    }
+// Locale язык, на котором выводятся даты.
+func (p *Parser) Locale() *locale.Locale {
+	return p.loc
+}
 
-    day, err := strconv.Atoi(matches[1])
-    if err != nil || day < 1 || day > 31 {
-        return time.Time{}, "", false
-    }
+func (p *Parser) ParseEventList(text string) []*EventEntry {
+	return p.ParseEventListWithEntities(text, nil)
+}
 
-    month, ok := monthNames[matches[2]]
-    if !ok {
-        return time.Time{}, "", false
-    }
+// ParseEventListWithEntities разбирает список событий и переносит entities
+// сообщения на описания событий, к строкам которых они относятся.
+func (p *Parser) ParseEventListWithEntities(text string, entities []Entity) []*EventEntry {
+	var offsets []int
+	if len(entities) > 0 {
+		offsets = utf16Offsets(text)
+	}
+
+	var events []*EventEntry
+	lineStart := 0
+	for _, raw := range strings.Split(text, "\n") {
+		rawStart := lineStart
+		lineStart += len(raw) + 1
+
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+
+		event := p.parseEventLine(line)
+		if event == nil {
+			continue
+		}
+		if event.IsValid && len(entities) > 0 {
+			start := rawStart + strings.Index(raw, line)
+			event.DescriptionEntities = anchorEntities(event.Description, text, offsets, entities, start, start+len(line))
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
 
-    description := strings.TrimSpace(matches[3])
+func (p *Parser) parseEventLine(line string) *EventEntry {
+	entry := &EventEntry{
+		RawDate: line,
+		IsValid: false,
+	}
+
+	today := startOfDay(p.clock.Now())
+	start, end, rest, found := parseDateRange(line)
+	if !found {
+		return entry
+	}
+
+	hour, minute, rest, hasTime := parseTimeOfDay(rest)
+
+	description, ok := cutDescription(rest)
+	if !ok {
+		return entry
+	}
+
+	description, leadTimes := extractLeadTimes(description)
+	if description == "" {
+		return entry
+	}
+
+	startDate, endDate, ok := resolveRange(start, end, today)
+	if !ok {
+		return entry
+	}
+
+	if hasTime {
+		startDate = startDate.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+		if start == end {
+			endDate = startDate
+		}
+	}
+
+	entry.Date = startDate
+	entry.EndDate = endDate
+	entry.Description = description
+	entry.IsValid = true
+	entry.HasYear = start.year != 0 || end.year != 0
+	entry.Expired = entry.HasYear && endDate.Before(today)
+	entry.HasTime = hasTime
+	entry.LeadTimes = leadTimes
+
+	return entry
+}
 
-    now := time.Now()
-    year := now.Year()
+// parseTimeOfDay разбирает время после даты: "19:30", "19.30", "в 19:30".
+// Если времени нет, rest возвращается без изменений.
+func parseTimeOfDay(rest string) (int, int, string, bool) {
+	m := timeOfDayRe.FindStringSubmatch(rest)
+	if m == nil {
+		return 0, 0, rest, false
+	}
 
-    dateToCheck := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
-    if dateToCheck.Before(now) && month < int(now.Month()) {
-        year++
-        dateToCheck = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
-    }
+	hour, minute := atoi(m[1]), atoi(m[2])
+	if hour > 23 || minute > 59 {
+		return 0, 0, rest, false
+	}
 
-    return dateToCheck, description, true
+	return hour, minute, rest[len(m[0]):], true
 }
 
-func tryParseDotFormat(line string) (time.Time, string, bool) {
-    re := regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})\s+(.*)$`)
-    matches := re.FindStringSubmatch(line)
+// parseDate разбирает дату в начале строки в одном из форматов
+// yyyy-mm-dd, dd.mm, dd.mm.yy, dd.mm.yyyy, "5 марта", "5 марта 2027",
+// "5 March", "5 мар" и возвращает остаток строки. Регистр названия месяца
+// не важен. Четыре цифры после названия месяца считаются годом, только если
+// он между minYear и maxYear.
+func parseDate(s string) (dateSpec, string, bool) {
+	if m := isoDateRe.FindStringSubmatch(s); m != nil {
+		spec := dateSpec{day: atoi(m[3]), month: atoi(m[2]), year: atoi(m[1])}
+		return spec, s[len(m[0]):], true
+	}
+
+	if m := dotDateRe.FindStringSubmatch(s); m != nil {
+		spec := dateSpec{day: atoi(m[1]), month: atoi(m[2])}
+		switch len(m[3]) {
+		case 2:
+			spec.year = 2000 + atoi(m[3])
+		case 4:
+			spec.year = atoi(m[3])
+		}
+		return spec, s[len(m[0]):], true
+	}
+
+	if m := monthDateRe.FindStringSubmatch(s); m != nil {
+		spec := dateSpec{day: atoi(m[1]), month: monthNames[strings.ToLower(m[2])]}
+		rest := s[len(m[0]):]
+		if y := monthYearRe.FindStringSubmatch(rest); y != nil {
+			year := atoi(y[1])
+			if year >= minYear && year <= maxYear {
+				spec.year = year
+				rest = rest[len(y[0]):]
+			}
+		}
+		return spec, rest, true
+	}
+
+	return dateSpec{}, "", false
+}
 
-    if len(matches) < 4 {
-        return time.Time{}, "", false
-    }
-
-    day, err := strconv.Atoi(matches[1])
-    if err != nil || day < 1 || day > 31 {
-        return time.Time{}, "", false
-    }
+// parseDateRange разбирает одиночную дату или диапазон дат в начале строки:
+// "12-15.06", "28.06-03.07", "28 июня - 3 июля". Для одиночной даты
+// start и end совпадают.
+func parseDateRange(line string) (dateSpec, dateSpec, string, bool) {
+	if m := rangeStartRe.FindStringSubmatch(line); m != nil {
+		if end, rest, ok := parseDate(line[len(m[0]):]); ok {
+			start := end
+			start.day = atoi(m[1])
+			return start, end, rest, true
+		}
+	}
+
+	start, rest, ok := parseDate(line)
+	if !ok {
+		return dateSpec{}, dateSpec{}, "", false
+	}
+
+	if sep := rangeSepRe.FindString(rest); sep != "" {
+		if end, endRest, ok := parseDate(rest[len(sep):]); ok {
+			return start, end, endRest, true
+		}
+	}
+
+	return start, start, rest, true
+}
 
-    month, err := strconv.Atoi(matches[2])
-    if err != nil || month < 1 || month > 12 {
-        return time.Time{}, "", false
-    }
+// cutDescription отделяет описание события от даты. Дата должна быть
+// отделена от описания пробелом, иначе строка не считается событием.
+func cutDescription(rest string) (string, bool) {
+	trimmed := strings.TrimLeftFunc(rest, unicode.IsSpace)
+	if len(trimmed) == len(rest) && rest != "" {
+		return "", false
+	}
+	description := strings.TrimSpace(strings.TrimLeft(trimmed, "-–—: "))
+	if description == "" {
+		return "", false
+	}
+	return description, true
+}
 
-    description := strings.TrimSpace(matches[3])
+// resolveDate превращает dateSpec в дату. Год, указанный явно, используется
+// как есть, иначе берется ближайшая дата не раньше today.
+func resolveDate(spec dateSpec, today time.Time) (time.Time, bool) {
+	if spec.day < 1 || spec.day > 31 || spec.month < 1 || spec.month > 12 {
+		return time.Time{}, false
+	}
+	if spec.year != 0 {
+		return makeDate(spec.year, spec.month, spec.day, today.Location())
+	}
+	return nextOccurrence(spec.day, spec.month, today)
+}
 
-    now := time.Now()
-    year := now.Year()
+// resolveRange превращает границы диапазона в даты. Недостающий год
+// берется у другой границы, а диапазон без года выбирается так, чтобы
+// он заканчивался не раньше today: идущие сейчас события не переносятся
+// на следующий год.
+func resolveRange(start, end dateSpec, today time.Time) (time.Time, time.Time, bool) {
+	if start == end {
+		date, ok := resolveDate(start, today)
+		return date, date, ok
+	}
+
+	switch {
+	case start.year == 0 && end.year != 0:
+		start.year = end.year
+		if dayOfYearAfter(start, end) {
+			start.year--
+		}
+	case start.year != 0 && end.year == 0:
+		end.year = start.year
+		if dayOfYearAfter(start, end) {
+			end.year++
+		}
+	}
+
+	endDate, ok := resolveDate(end, today)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if start.year == 0 {
+		start.year = endDate.Year()
+		if dayOfYearAfter(start, end) {
+			start.year--
+		}
+	}
+
+	startDate, ok := resolveDate(start, today)
+	if !ok || endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return startDate, endDate, true
+}
 
-    dateToCheck := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
-    if dateToCheck.Before(now) {
-        year++
-        dateToCheck = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
-    }
+// dayOfYearAfter день и месяц a идут в году позже, чем у b.
+func dayOfYearAfter(a, b dateSpec) bool {
+	if a.month != b.month {
+		return a.month > b.month
+	}
+	return a.day > b.day
+}
 
-    return dateToCheck, description, true
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
 }
 
-func tryParseRangeFormat(line string) (time.Time, string, bool) {
-    re := regexp.MustCompile(`^(\d{1,2})-(\d{1,2})\.(\d{1,2})\s+(.*)$`)
-    matches := re.FindStringSubmatch(line)
+// nextOccurrence возвращает ближайшую дату с указанными днем и месяцем,
+// не раньше today. Для 29 февраля ищется ближайший високосный год.
+func nextOccurrence(day, month int, today time.Time) (time.Time, bool) {
+	for year := today.Year(); year <= today.Year()+8; year++ {
+		date, ok := makeDate(year, month, day, today.Location())
+		if !ok {
+			continue
+		}
+		if !date.Before(today) {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
 
-    if len(matches) < 5 {
-        return time.Time{}, "", false
-    }
+// makeDate собирает дату и проверяет, что такой день существует
+// (time.Date молча превращает 31.04 в 01.05).
+func makeDate(year, month, day int, loc *time.Location) (time.Time, bool) {
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
+	if date.Year() != year || int(date.Month()) != month || date.Day() != day {
+		return time.Time{}, false
+	}
+	return date, true
+}
 
-    startDay, err := strconv.Atoi(matches[1])
-    if err != nil || startDay < 1 || startDay > 31 {
-        return time.Time{}, "", false
-    }
+// GetUpcomingEvents возвращает события, которые начинаются сегодня
+// или в ближайшие daysAhead дней включительно, а также уже идущие
+// многодневные события.
+func (p *Parser) GetUpcomingEvents(events []*EventEntry, daysAhead int) []*EventEntry {
+	var upcoming []*EventEntry
+	today := startOfDay(p.clock.Now())
+	windowEnd := today.AddDate(0, 0, daysAhead+1)
+
+	for _, event := range events {
+		if !event.IsValid || event.Expired {
+			continue
+		}
+		if event.Date.Before(windowEnd) && !event.EndDate.Before(today) {
+			upcoming = append(upcoming, event)
+		}
+	}
+
+	return upcoming
+}
 
-    month, err := strconv.Atoi(matches[3])
-    if err != nil || month < 1 || month > 12 {
-        return time.Time{}, "", false
-    }
+// GetNextEvents возвращает ближайшие события, которые начинаются сегодня или
+// позже. Если в этот день несколько событий, возвращаются все.
+func (p *Parser) GetNextEvents(events []*EventEntry) []*EventEntry {
+	var next []*EventEntry
+	today := startOfDay(p.clock.Now())
+
+	for _, event := range events {
+		if !event.IsValid || event.Expired || event.Date.Before(today) {
+			continue
+		}
+		switch {
+		case len(next) == 0:
+			next = append(next, event)
+		case startOfDay(event.Date).Before(startOfDay(next[0].Date)):
+			next = []*EventEntry{event}
+		case startOfDay(event.Date).Equal(startOfDay(next[0].Date)):
+			next = append(next, event)
+		}
+	}
+
+	return next
+}
 
-    description := strings.TrimSpace(matches[4])
+// GetEventsStartingWithin возвращает события с указанным временем начала,
+// до которых осталось не больше lead.
+func (p *Parser) GetEventsStartingWithin(events []*EventEntry, lead time.Duration) []*EventEntry {
+	var starting []*EventEntry
+	now := p.clock.Now()
+
+	for _, event := range events {
+		if !event.IsValid || event.Expired || !event.HasTime {
+			continue
+		}
+		if event.Date.After(now) && !event.Date.After(now.Add(lead)) {
+			starting = append(starting, event)
+		}
+	}
+
+	return starting
+}
 
-    now := time.Now()
-    year := now.Year()
+// FormatEventForMessage форматирует событие для напоминания. Для многодневных
+// событий добавляется, когда событие начнется или закончится.
+func (p *Parser) FormatEventForMessage(event *EventEntry) string {
+	text, _ := p.FormatEventForMessageWithEntities(event)
+	return text
+}
 
-    dateToCheck := time.Date(year, time.Month(month), startDay, 0, 0, 0, 0, time.Local)
-    if dateToCheck.Before(now) {
-        year++
-        dateToCheck = time.Date(year, time.Month(month), startDay, 0, 0, 0, 0, time.Local)
-    }
+// FormatEventForMessageWithEntities форматирует событие так же, как
+// FormatEventForMessage, и возвращает его форматирование: дата выделяется
+// жирным, entities описания сдвигаются на его позицию в строке.
+func (p *Parser) FormatEventForMessageWithEntities(event *EventEntry) (string, []Entity) {
+	if !event.IsValid {
+		return "", nil
+	}
+
+	const icon = "📅 "
+	dateStr := p.FormatEventDate(event)
+	prefix := icon + dateStr + " - "
+	entities := append([]Entity{{Type: "bold", Offset: UTF16Len(icon), Length: UTF16Len(dateStr)}},
+		ShiftEntities(event.DescriptionEntities, UTF16Len(prefix))...)
+
+	status := p.EventStatus(event)
+	if status == "" {
+		return prefix + event.Description, entities
+	}
+	return fmt.Sprintf("%s%s (%s)", prefix, event.Description, status), entities
+}
 
-    return dateToCheck, description, true
+// FormatEventDate дата события для сообщения: день и месяц, время начала,
+// если оно указано, и дата окончания для многодневных событий.
+func (p *Parser) FormatEventDate(event *EventEntry) string {
+	dateStr := p.loc.FormatDate(event.Date)
+	if event.HasTime {
+		dateStr += " " + event.Date.Format("15:04")
+	}
+	if event.IsRange() {
+		dateStr = fmt.Sprintf("%s – %s", dateStr, p.loc.FormatDate(event.EndDate))
+	}
+	return dateStr
 }
 
-func GetUpcomingEvents(events []*EventEntry, daysAhead int) []*EventEntry {
-    var upcoming []*EventEntry
-    now := time.Now()
-    targetDate := now.AddDate(0, 0, daysAhead)
+// FormatWeekday день недели начала события.
+func (p *Parser) FormatWeekday(event *EventEntry) string {
+	return p.loc.Weekday(event.Date)
+}
 
-    for _, event := range events {
-        if !event.IsValid {
-            continue
-        }
-        if (event.Date.Equal(now) || event.Date.After(now)) && event.Date.Before(targetDate.AddDate(0, 0, 1)) {
-            upcoming = append(upcoming, event)
-        }
-    }
+// EventStatus когда многодневное событие начнется или закончится. Для
+// однодневных событий пусто.
+func (p *Parser) EventStatus(event *EventEntry) string {
+	if !event.IsRange() {
+		return ""
+	}
+
+	today := startOfDay(p.clock.Now())
+	if event.Date.After(today) {
+		return p.loc.StartsIn(daysBetween(today, event.Date))
+	}
+	return p.loc.EndsIn(daysBetween(today, event.EndDate))
+}
 
-    return upcoming
+// DaysUntil через сколько календарных дней начнется событие; для уже
+// начавшихся событий 0 или меньше.
+func (p *Parser) DaysUntil(event *EventEntry) int {
+	return daysBetween(p.clock.Now(), event.Date)
 }
 
-func FormatEventForMessage(event *EventEntry) string {
-    if !event.IsValid {
-        return ""
-    }
-    dateStr := event.Date.Format("02 January")
-    return fmt.Sprintf("📅 %s - %s", dateStr, event.Description)
+// daysBetween количество календарных дней от from до to.
+func daysBetween(from, to time.Time) int {
+	from = startOfDay(from)
+	to = startOfDay(to)
+	return int(math.Round(to.Sub(from).Hours() / 24))
 }
@@ -0,0 +1,619 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func dateTime(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func clockAt(year int, month time.Month, day, hour int) Clock {
+	return FixedClock{Time: time.Date(year, month, day, hour, 0, 0, 0, time.UTC)}
+}
+
+func TestParseEventLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		now       Clock
+		line      string
+		wantValid bool
+		wantDate  time.Time
+		wantDescr string
+		wantEnd   time.Time
+		wantYear  bool
+		wantExpir bool
+		wantTime  bool
+	}{
+		{
+			name:      "dot format this year",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "15.03 День рождения",
+			wantValid: true,
+			wantDate:  date(2026, time.March, 15),
+			wantDescr: "День рождения",
+		},
+		{
+			name:      "russian format this year",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "15 марта День рождения",
+			wantValid: true,
+			wantDate:  date(2026, time.March, 15),
+			wantDescr: "День рождения",
+		},
+		{
+			name:      "range format keeps start and end day",
+			now:       clockAt(2026, time.June, 1, 9),
+			line:      "12-15.06 Поездка",
+			wantValid: true,
+			wantDate:  date(2026, time.June, 12),
+			wantEnd:   date(2026, time.June, 15),
+			wantDescr: "Поездка",
+		},
+		{
+			name:      "dot format december to january rollover",
+			now:       clockAt(2026, time.December, 30, 9),
+			line:      "02.01 Встреча",
+			wantValid: true,
+			wantDate:  date(2027, time.January, 2),
+			wantDescr: "Встреча",
+		},
+		{
+			name:      "russian format december to january rollover",
+			now:       clockAt(2026, time.December, 30, 9),
+			line:      "2 января Встреча",
+			wantValid: true,
+			wantDate:  date(2027, time.January, 2),
+			wantDescr: "Встреча",
+		},
+		{
+			name:      "number outside the year range stays in description",
+			now:       clockAt(2026, time.March, 1, 9),
+			line:      "5 марта 1500 участников",
+			wantValid: true,
+			wantDate:  date(2026, time.March, 5),
+			wantDescr: "1500 участников",
+		},
+		{
+			name:      "past year close to current marks event expired",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "5 марта 2025 Юбилей",
+			wantValid: true,
+			wantDate:  date(2025, time.March, 5),
+			wantDescr: "Юбилей",
+			wantYear:  true,
+			wantExpir: true,
+		},
+		{
+			name:      "year more than ten years ahead after month name",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "5 марта 2037 Конференция",
+			wantValid: true,
+			wantDate:  date(2037, time.March, 5),
+			wantDescr: "Конференция",
+			wantYear:  true,
+		},
+		{
+			name:      "month name and dot formats agree on a far year",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "05.03.2037 Конференция",
+			wantValid: true,
+			wantDate:  date(2037, time.March, 5),
+			wantDescr: "Конференция",
+			wantYear:  true,
+		},
+		{
+			name:      "year after the range stays in description",
+			now:       clockAt(2026, time.March, 1, 9),
+			line:      "5 марта 2100 шагов",
+			wantValid: true,
+			wantDate:  date(2026, time.March, 5),
+			wantDescr: "2100 шагов",
+		},
+		{
+			name:      "english month name",
+			now:       clockAt(2026, time.March, 1, 9),
+			line:      "5 March Birthday",
+			wantValid: true,
+			wantDate:  date(2026, time.March, 5),
+			wantDescr: "Birthday",
+		},
+		{
+			name:      "english abbreviation with dot and year",
+			now:       clockAt(2026, time.March, 1, 9),
+			line:      "5 Sept. 2027 Conference",
+			wantValid: true,
+			wantDate:  date(2027, time.September, 5),
+			wantDescr: "Conference",
+			wantYear:  true,
+		},
+		{
+			name:      "russian abbreviation in upper case",
+			now:       clockAt(2026, time.March, 1, 9),
+			line:      "5 МАР Встреча",
+			wantValid: true,
+			wantDate:  date(2026, time.March, 5),
+			wantDescr: "Встреча",
+		},
+		{
+			name:      "russian nominative month",
+			now:       clockAt(2026, time.January, 1, 9),
+			line:      "10 янв Каникулы",
+			wantValid: true,
+			wantDate:  date(2026, time.January, 10),
+			wantDescr: "Каникулы",
+		},
+		{
+			name:      "range with english abbreviations",
+			now:       clockAt(2026, time.June, 1, 9),
+			line:      "28 Jun - 3 Jul Vacation",
+			wantValid: true,
+			wantDate:  date(2026, time.June, 28),
+			wantEnd:   date(2026, time.July, 3),
+			wantDescr: "Vacation",
+		},
+		{
+			name:      "month name without space before description",
+			now:       clockAt(2026, time.March, 1, 9),
+			line:      "5 мартовский кот",
+			wantValid: false,
+		},
+		{
+			name:      "range format december to january rollover",
+			now:       clockAt(2026, time.December, 30, 9),
+			line:      "3-5.01 Каникулы",
+			wantValid: true,
+			wantDate:  date(2027, time.January, 3),
+			wantEnd:   date(2027, time.January, 5),
+			wantDescr: "Каникулы",
+		},
+		{
+			name:      "earlier day in current month rolls to next year",
+			now:       clockAt(2026, time.December, 30, 9),
+			line:      "28 декабря Корпоратив",
+			wantValid: true,
+			wantDate:  date(2027, time.December, 28),
+			wantDescr: "Корпоратив",
+		},
+		{
+			name:      "event today is not rolled over",
+			now:       clockAt(2026, time.December, 30, 18),
+			line:      "30.12 Сегодня",
+			wantValid: true,
+			wantDate:  date(2026, time.December, 30),
+			wantDescr: "Сегодня",
+		},
+		{
+			name:      "leap day in leap year",
+			now:       clockAt(2028, time.February, 1, 9),
+			line:      "29.02 Високосный",
+			wantValid: true,
+			wantDate:  date(2028, time.February, 29),
+			wantDescr: "Високосный",
+		},
+		{
+			name:      "leap day moves to next leap year",
+			now:       clockAt(2026, time.February, 1, 9),
+			line:      "29 февраля Високосный",
+			wantValid: true,
+			wantDate:  date(2028, time.February, 29),
+			wantDescr: "Високосный",
+		},
+		{
+			name:      "full year in dot format",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "05.03.2028 Юбилей",
+			wantValid: true,
+			wantDate:  date(2028, time.March, 5),
+			wantDescr: "Юбилей",
+			wantYear:  true,
+		},
+		{
+			name:      "short year in dot format",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "05.03.27 Юбилей",
+			wantValid: true,
+			wantDate:  date(2027, time.March, 5),
+			wantDescr: "Юбилей",
+			wantYear:  true,
+		},
+		{
+			name:      "iso format",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "2027-03-05 Юбилей",
+			wantValid: true,
+			wantDate:  date(2027, time.March, 5),
+			wantDescr: "Юбилей",
+			wantYear:  true,
+		},
+		{
+			name:      "russian format with year",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "5 марта 2027 Юбилей",
+			wantValid: true,
+			wantDate:  date(2027, time.March, 5),
+			wantDescr: "Юбилей",
+			wantYear:  true,
+		},
+		{
+			name:      "range with year",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "12-15.06.2027 Поездка",
+			wantValid: true,
+			wantDate:  date(2027, time.June, 12),
+			wantEnd:   date(2027, time.June, 15),
+			wantDescr: "Поездка",
+			wantYear:  true,
+		},
+		{
+			name:      "past explicit year is expired, not rolled forward",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "05.03.2026 Прошедшее",
+			wantValid: true,
+			wantDate:  date(2026, time.March, 5),
+			wantDescr: "Прошедшее",
+			wantYear:  true,
+			wantExpir: true,
+		},
+		{
+			name:      "explicit leap day in non-leap year is invalid",
+			now:       clockAt(2026, time.February, 1, 9),
+			line:      "29.02.2027 Високосный",
+			wantValid: false,
+		},
+		{
+			name:      "date glued to description is invalid",
+			now:       clockAt(2026, time.February, 1, 9),
+			line:      "15.03.2027Юбилей",
+			wantValid: false,
+		},
+		{
+			name:      "cross-month range in dot format",
+			now:       clockAt(2026, time.June, 1, 9),
+			line:      "28.06-03.07 Отпуск",
+			wantValid: true,
+			wantDate:  date(2026, time.June, 28),
+			wantEnd:   date(2026, time.July, 3),
+			wantDescr: "Отпуск",
+		},
+		{
+			name:      "cross-month range in russian format",
+			now:       clockAt(2026, time.June, 1, 9),
+			line:      "28 июня - 3 июля Отпуск",
+			wantValid: true,
+			wantDate:  date(2026, time.June, 28),
+			wantEnd:   date(2026, time.July, 3),
+			wantDescr: "Отпуск",
+		},
+		{
+			name:      "day range in russian format",
+			now:       clockAt(2026, time.June, 1, 9),
+			line:      "12–15 июня Поездка",
+			wantValid: true,
+			wantDate:  date(2026, time.June, 12),
+			wantEnd:   date(2026, time.June, 15),
+			wantDescr: "Поездка",
+		},
+		{
+			name:      "ongoing range is not rolled to next year",
+			now:       clockAt(2026, time.June, 30, 9),
+			line:      "28.06-03.07 Отпуск",
+			wantValid: true,
+			wantDate:  date(2026, time.June, 28),
+			wantEnd:   date(2026, time.July, 3),
+			wantDescr: "Отпуск",
+		},
+		{
+			name:      "ongoing range across new year",
+			now:       clockAt(2027, time.January, 2, 9),
+			line:      "28.12-03.01 Каникулы",
+			wantValid: true,
+			wantDate:  date(2026, time.December, 28),
+			wantEnd:   date(2027, time.January, 3),
+			wantDescr: "Каникулы",
+		},
+		{
+			name:      "range with year on end only",
+			now:       clockAt(2026, time.June, 1, 9),
+			line:      "28.12-03.01.2028 Каникулы",
+			wantValid: true,
+			wantDate:  date(2027, time.December, 28),
+			wantEnd:   date(2028, time.January, 3),
+			wantDescr: "Каникулы",
+			wantYear:  true,
+		},
+		{
+			name:      "dash after single date is not part of description",
+			now:       clockAt(2026, time.June, 1, 9),
+			line:      "15.06 - День рождения",
+			wantValid: true,
+			wantDate:  date(2026, time.June, 15),
+			wantDescr: "День рождения",
+		},
+		{
+			name:      "range ending before start is invalid",
+			now:       clockAt(2026, time.June, 1, 9),
+			line:      "15-12.06.2026 Поездка",
+			wantValid: false,
+		},
+		{
+			name:      "time with colon",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "15.03 19:30 Ужин команды",
+			wantValid: true,
+			wantDate:  dateTime(2026, time.March, 15, 19, 30),
+			wantDescr: "Ужин команды",
+			wantTime:  true,
+		},
+		{
+			name:      "time with dot",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "15.03.2026 19.30 Ужин команды",
+			wantValid: true,
+			wantDate:  dateTime(2026, time.March, 15, 19, 30),
+			wantDescr: "Ужин команды",
+			wantYear:  true,
+			wantTime:  true,
+		},
+		{
+			name:      "time with preposition",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "15 марта в 9:05 Созвон",
+			wantValid: true,
+			wantDate:  dateTime(2026, time.March, 15, 9, 5),
+			wantDescr: "Созвон",
+			wantTime:  true,
+		},
+		{
+			name:      "timed event today keeps current year",
+			now:       clockAt(2026, time.March, 15, 21),
+			line:      "15.03 19:30 Ужин команды",
+			wantValid: true,
+			wantDate:  dateTime(2026, time.March, 15, 19, 30),
+			wantDescr: "Ужин команды",
+			wantTime:  true,
+		},
+		{
+			name:      "invalid time stays in description",
+			now:       clockAt(2026, time.March, 10, 9),
+			line:      "15.03 25:30 Странное время",
+			wantValid: true,
+			wantDate:  date(2026, time.March, 15),
+			wantDescr: "25:30 Странное время",
+		},
+		{
+			name:      "nonexistent day is invalid",
+			now:       clockAt(2026, time.February, 1, 9),
+			line:      "31.04 Несуществующий день",
+			wantValid: false,
+		},
+		{
+			name:      "line without date is invalid",
+			now:       clockAt(2026, time.February, 1, 9),
+			line:      "Список событий:",
+			wantValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := NewParser(tt.now).ParseEventList(tt.line)
+			if len(events) != 1 {
+				t.Fatalf("ожидалось одно событие, получено %d", len(events))
+			}
+			event := events[0]
+			if event.IsValid != tt.wantValid {
+				t.Fatalf("IsValid = %v, ожидалось %v", event.IsValid, tt.wantValid)
+			}
+			if !tt.wantValid {
+				return
+			}
+			if !event.Date.Equal(tt.wantDate) {
+				t.Errorf("Date = %s, ожидалось %s", event.Date, tt.wantDate)
+			}
+			wantEnd := tt.wantEnd
+			if wantEnd.IsZero() {
+				wantEnd = tt.wantDate
+			}
+			if !event.EndDate.Equal(wantEnd) {
+				t.Errorf("EndDate = %s, ожидалось %s", event.EndDate, wantEnd)
+			}
+			if event.Description != tt.wantDescr {
+				t.Errorf("Description = %q, ожидалось %q", event.Description, tt.wantDescr)
+			}
+			if event.HasYear != tt.wantYear {
+				t.Errorf("HasYear = %v, ожидалось %v", event.HasYear, tt.wantYear)
+			}
+			if event.HasTime != tt.wantTime {
+				t.Errorf("HasTime = %v, ожидалось %v", event.HasTime, tt.wantTime)
+			}
+			if event.Expired != tt.wantExpir {
+				t.Errorf("Expired = %v, ожидалось %v", event.Expired, tt.wantExpir)
+			}
+		})
+	}
+}
+
+func TestGetUpcomingEvents(t *testing.T) {
+	text := "30.12 Сегодня\n31.12 Завтра\n04.01 Через пять дней\n05.01 Через шесть дней\nбез даты\n29.12.2026 Прошло\n28.12-02.01 Каникулы"
+
+	tests := []struct {
+		name      string
+		now       Clock
+		daysAhead int
+		want      []string
+	}{
+		{
+			name:      "window crosses new year",
+			now:       clockAt(2026, time.December, 30, 9),
+			daysAhead: 5,
+			want:      []string{"Сегодня", "Завтра", "Через пять дней", "Каникулы"},
+		},
+		{
+			name:      "event today is included late in the day",
+			now:       clockAt(2026, time.December, 30, 23),
+			daysAhead: 0,
+			want:      []string{"Сегодня", "Каникулы"},
+		},
+		{
+			name:      "ongoing range is included",
+			now:       clockAt(2027, time.January, 2, 9),
+			daysAhead: 1,
+			want:      []string{"Каникулы"},
+		},
+		{
+			name:      "boundary day is included",
+			now:       clockAt(2026, time.December, 30, 9),
+			daysAhead: 6,
+			want:      []string{"Сегодня", "Завтра", "Через пять дней", "Через шесть дней", "Каникулы"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(tt.now)
+			upcoming := p.GetUpcomingEvents(p.ParseEventList(text), tt.daysAhead)
+
+			if len(upcoming) != len(tt.want) {
+				t.Fatalf("получено %d событий, ожидалось %d", len(upcoming), len(tt.want))
+			}
+			for i, event := range upcoming {
+				if event.Description != tt.want[i] {
+					t.Errorf("событие %d = %q, ожидалось %q", i, event.Description, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFormatEventForMessageRange(t *testing.T) {
+	tests := []struct {
+		name string
+		now  Clock
+		want string
+	}{
+		{
+			name: "range starts later",
+			now:  clockAt(2026, time.June, 25, 9),
+			want: "📅 28 июня – 3 июля - Отпуск (начнется через 3 дня)",
+		},
+		{
+			name: "range starts tomorrow",
+			now:  clockAt(2026, time.June, 27, 9),
+			want: "📅 28 июня – 3 июля - Отпуск (начнется завтра)",
+		},
+		{
+			name: "range is ongoing",
+			now:  clockAt(2026, time.June, 30, 9),
+			want: "📅 28 июня – 3 июля - Отпуск (идет сейчас, закончится через 3 дня)",
+		},
+		{
+			name: "range ends today",
+			now:  clockAt(2026, time.July, 3, 9),
+			want: "📅 28 июня – 3 июля - Отпуск (идет сейчас, закончится сегодня)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(tt.now)
+			events := p.ParseEventList("28.06-03.07 Отпуск")
+			if got := p.FormatEventForMessage(events[0]); got != tt.want {
+				t.Errorf("получено %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEventsStartingWithin(t *testing.T) {
+	text := "15.03 10:00 Утро\n15.03 19:30 Ужин\n15.03 Без времени\n16.03 09:00 Завтра"
+
+	tests := []struct {
+		name string
+		now  Clock
+		lead time.Duration
+		want []string
+	}{
+		{
+			name: "only events within lead",
+			now:  clockAt(2026, time.March, 15, 8),
+			lead: 3 * time.Hour,
+			want: []string{"Утро"},
+		},
+		{
+			name: "started events are skipped",
+			now:  clockAt(2026, time.March, 15, 17),
+			lead: 3 * time.Hour,
+			want: []string{"Ужин"},
+		},
+		{
+			name: "lead crosses midnight",
+			now:  clockAt(2026, time.March, 15, 22),
+			lead: 12 * time.Hour,
+			want: []string{"Завтра"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(tt.now)
+			starting := p.GetEventsStartingWithin(p.ParseEventList(text), tt.lead)
+
+			if len(starting) != len(tt.want) {
+				t.Fatalf("получено %d событий, ожидалось %d", len(starting), len(tt.want))
+			}
+			for i, event := range starting {
+				if event.Description != tt.want[i] {
+					t.Errorf("событие %d = %q, ожидалось %q", i, event.Description, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetNextEvents(t *testing.T) {
+	text := "28.12-02.01 Каникулы\n05.01 Встреча\n03.01 Первый\n03.01 18:00 Второй\n29.12.2026 Прошло"
+
+	tests := []struct {
+		name string
+		now  Clock
+		want []string
+	}{
+		{
+			name: "earliest day with all its events",
+			now:  clockAt(2026, time.December, 30, 9),
+			want: []string{"Первый", "Второй"},
+		},
+		{
+			name: "event today is next",
+			now:  clockAt(2027, time.January, 5, 20),
+			want: []string{"Встреча"},
+		},
+		{
+			name: "range starting later is next",
+			now:  clockAt(2027, time.December, 10, 9),
+			want: []string{"Каникулы"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(tt.now)
+			next := p.GetNextEvents(p.ParseEventList(text))
+
+			if len(next) != len(tt.want) {
+				t.Fatalf("получено %d событий, ожидалось %d", len(next), len(tt.want))
+			}
+			for i, event := range next {
+				if event.Description != tt.want[i] {
+					t.Errorf("событие %d = %q, ожидалось %q", i, event.Description, tt.want[i])
+				}
+			}
+		})
+	}
+}
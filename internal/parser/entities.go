@@ -0,0 +1,110 @@
+package parser
+
+import "strings"
+
+// Entity форматирование части текста сообщения Telegram: жирный текст,
+// ссылка, упоминание и т.п. Offset и Length считаются в единицах UTF-16,
+// как в Bot API.
+type Entity struct {
+	Type   string
+	Offset int
+	Length int
+	// URL для text_link.
+	URL string
+	// UserID для text_mention.
+	UserID int64
+	// Language для pre.
+	Language string
+}
+
+// UTF16Len длина строки в единицах UTF-16.
+func UTF16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// ShiftEntities сдвигает смещения entities на offset единиц UTF-16.
+func ShiftEntities(entities []Entity, offset int) []Entity {
+	shifted := make([]Entity, len(entities))
+	for i, e := range entities {
+		e.Offset += offset
+		shifted[i] = e
+	}
+	return shifted
+}
+
+// utf16Offsets сопоставляет смещению в UTF-16 байтовое смещение в s.
+// Последний элемент соответствует концу строки.
+func utf16Offsets(s string) []int {
+	offsets := make([]int, 0, len(s)+1)
+	for i, r := range s {
+		offsets = append(offsets, i)
+		if r >= 0x10000 {
+			offsets = append(offsets, i)
+		}
+	}
+	return append(offsets, len(s))
+}
+
+// anchorEntities переносит entities строки text[lineStart:lineEnd] на описание
+// события. Если описание входит в строку без изменений, entities обрезаются по
+// его границам. Если из описания удалены отметки вроде "(за 7 дней)", текст
+// каждой entity ищется в описании по порядку. Entities, выходящие за пределы
+// строки, пропускаются.
+func anchorEntities(description, text string, offsets []int, entities []Entity, lineStart, lineEnd int) []Entity {
+	var anchored []Entity
+
+	descStart := strings.Index(text[lineStart:lineEnd], description)
+	if descStart >= 0 {
+		descStart += lineStart
+		descEnd := descStart + len(description)
+		for _, e := range entities {
+			start, end, ok := entityBytes(e, offsets)
+			if !ok || start < lineStart || end > lineEnd {
+				continue
+			}
+			start, end = max(start, descStart), min(end, descEnd)
+			if start >= end {
+				continue
+			}
+			e.Offset = UTF16Len(text[descStart:start])
+			e.Length = UTF16Len(text[start:end])
+			anchored = append(anchored, e)
+		}
+		return anchored
+	}
+
+	searchFrom := 0
+	for _, e := range entities {
+		start, end, ok := entityBytes(e, offsets)
+		if !ok || start < lineStart || end > lineEnd {
+			continue
+		}
+		fragment := text[start:end]
+		idx := strings.Index(description[searchFrom:], fragment)
+		if idx < 0 {
+			continue
+		}
+		pos := searchFrom + idx
+		e.Offset = UTF16Len(description[:pos])
+		e.Length = UTF16Len(fragment)
+		anchored = append(anchored, e)
+		searchFrom = pos
+	}
+	return anchored
+}
+
+// entityBytes байтовые границы entity в тексте.
+func entityBytes(e Entity, offsets []int) (int, int, bool) {
+	if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length >= len(offsets) {
+		return 0, 0, false
+	}
+	return offsets[e.Offset], offsets[e.Offset+e.Length], true
+}
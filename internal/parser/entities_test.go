@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseEventListWithEntities(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []Entity
+		want     []Entity
+	}{
+		{
+			name:     "bold name",
+			text:     "Список\n15.03 День рождения Ивана",
+			entities: []Entity{{Type: "bold", Offset: 27, Length: 5}},
+			want:     []Entity{{Type: "bold", Offset: 14, Length: 5}},
+		},
+		{
+			name:     "whole line is clipped to description",
+			text:     "Список\n15.03 День рождения Ивана",
+			entities: []Entity{{Type: "bold", Offset: 7, Length: 25}},
+			want:     []Entity{{Type: "bold", Offset: 0, Length: 19}},
+		},
+		{
+			name:     "emoji before line takes two units",
+			text:     "🎉 Праздник\n15.03 Встреча с Петей",
+			entities: []Entity{{Type: "text_link", Offset: 28, Length: 5, URL: "https://example.com"}},
+			want:     []Entity{{Type: "text_link", Offset: 10, Length: 5, URL: "https://example.com"}},
+		},
+		{
+			name:     "entity after removed marker",
+			text:     "15.03 Встреча (за 7 дней) с Петей",
+			entities: []Entity{{Type: "text_mention", Offset: 28, Length: 5, UserID: 42}},
+			want:     []Entity{{Type: "text_mention", Offset: 10, Length: 5, UserID: 42}},
+		},
+		{
+			name:     "entity outside event line is dropped",
+			text:     "Список\n15.03 Встреча",
+			entities: []Entity{{Type: "bold", Offset: 0, Length: 6}},
+		},
+	}
+
+	p := NewParser(clockAt(2026, time.March, 10, 9))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := p.ParseEventListWithEntities(tt.text, tt.entities)
+			var valid []*EventEntry
+			for _, e := range events {
+				if e.IsValid {
+					valid = append(valid, e)
+				}
+			}
+			if len(valid) != 1 {
+				t.Fatalf("ожидалось одно событие, получено %d", len(valid))
+			}
+			if !reflect.DeepEqual(valid[0].DescriptionEntities, tt.want) {
+				t.Errorf("entities = %+v, ожидалось %+v", valid[0].DescriptionEntities, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatEventForMessageWithEntities(t *testing.T) {
+	p := NewParser(clockAt(2026, time.March, 10, 9))
+	events := p.ParseEventListWithEntities("15.03 День рождения Ивана", []Entity{{Type: "italic", Offset: 20, Length: 5}})
+
+	text, entities := p.FormatEventForMessageWithEntities(events[0])
+	if text != "📅 15 марта - День рождения Ивана" {
+		t.Fatalf("text = %q", text)
+	}
+	want := []Entity{
+		{Type: "bold", Offset: 3, Length: 8},
+		{Type: "italic", Offset: 28, Length: 5},
+	}
+	if !reflect.DeepEqual(entities, want) {
+		t.Errorf("entities = %+v, ожидалось %+v", entities, want)
+	}
+}
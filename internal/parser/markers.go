@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// "(за 7 дней)", "(за 3 и 1 день)", "(за 7, 1 дня)"
+	leadTimeRussianRe = regexp.MustCompile(`\(\s*за\s+(\d+(?:\s*(?:,|и)\s*\d+)*)\s*(?:день|дня|дней)\s*\)`)
+	// "[remind: 7d,1d]", "[напомнить: 7д, 1д]"
+	leadTimeBracketRe = regexp.MustCompile(`\[\s*(?:remind|напомнить)\s*:\s*([^\]]*)\]`)
+	leadTimeItemRe    = regexp.MustCompile(`^(\d+)\s*[dд]?$`)
+	numberRe          = regexp.MustCompile(`\d+`)
+	spacesRe          = regexp.MustCompile(`\s{2,}`)
+)
+
+// extractLeadTimes находит в описании отметки о том, за сколько дней
+// напоминать о событии, и возвращает описание без них. Дни возвращаются
+// по убыванию без повторов.
+func extractLeadTimes(description string) (string, []int) {
+	seen := make(map[int]bool)
+	var leadTimes []int
+	add := func(days int) {
+		if !seen[days] {
+			seen[days] = true
+			leadTimes = append(leadTimes, days)
+		}
+	}
+
+	description = leadTimeRussianRe.ReplaceAllStringFunc(description, func(marker string) string {
+		list := leadTimeRussianRe.FindStringSubmatch(marker)[1]
+		for _, number := range numberRe.FindAllString(list, -1) {
+			add(atoi(number))
+		}
+		return ""
+	})
+
+	description = leadTimeBracketRe.ReplaceAllStringFunc(description, func(marker string) string {
+		list := leadTimeBracketRe.FindStringSubmatch(marker)[1]
+		var days []int
+		for _, item := range strings.Split(list, ",") {
+			m := leadTimeItemRe.FindStringSubmatch(strings.TrimSpace(item))
+			if m == nil {
+				// Непонятная отметка остается в описании как есть.
+				return marker
+			}
+			days = append(days, atoi(m[1]))
+		}
+		for _, d := range days {
+			add(d)
+		}
+		return ""
+	})
+
+	sort.Sort(sort.Reverse(sort.IntSlice(leadTimes)))
+
+	description = strings.TrimSpace(spacesRe.ReplaceAllString(description, " "))
+	return description, leadTimes
+}
+
+// DueLeadTime возвращает отметку, по которой о событии пора напомнить сейчас:
+// наименьшее число дней, которое не меньше оставшегося до начала. Берутся
+// отметки события, а если их нет — defaults. Если бот пропустил запуск,
+// напоминание отправится по ближайшей наступившей отметке, а не по всем сразу.
+func (p *Parser) DueLeadTime(event *EventEntry, defaults []int) (int, bool) {
+	leadTimes := event.LeadTimes
+	if len(leadTimes) == 0 {
+		leadTimes = defaults
+	}
+	if !event.IsValid || event.Expired || len(leadTimes) == 0 {
+		return 0, false
+	}
+
+	today := startOfDay(p.clock.Now())
+	if event.EndDate.Before(today) {
+		return 0, false
+	}
+
+	daysUntil := daysBetween(today, event.Date)
+	due, found := 0, false
+	for _, days := range leadTimes {
+		if days >= daysUntil && (!found || days < due) {
+			due, found = days, true
+		}
+	}
+
+	return due, found
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLeadTimes(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantDescr string
+		wantLead  []int
+	}{
+		{
+			name:      "no markers",
+			line:      "15.03 День рождения",
+			wantDescr: "День рождения",
+		},
+		{
+			name:      "russian marker",
+			line:      "15.03 День рождения (за 7 дней)",
+			wantDescr: "День рождения",
+			wantLead:  []int{7},
+		},
+		{
+			name:      "russian marker with list",
+			line:      "15.03 (за 1 и 3 дня) День рождения",
+			wantDescr: "День рождения",
+			wantLead:  []int{3, 1},
+		},
+		{
+			name:      "bracket marker",
+			line:      "15.03 День рождения [remind: 7d,1d]",
+			wantDescr: "День рождения",
+			wantLead:  []int{7, 1},
+		},
+		{
+			name:      "several markers are merged",
+			line:      "15.03 День рождения [напомнить: 1д, 0] (за 7 дней)",
+			wantDescr: "День рождения",
+			wantLead:  []int{7, 1, 0},
+		},
+		{
+			name:      "unknown bracket marker stays in description",
+			line:      "15.03 День рождения [remind: soon]",
+			wantDescr: "День рождения [remind: soon]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := NewParser(clockAt(2026, time.March, 1, 9)).ParseEventList(tt.line)
+			event := events[0]
+			if !event.IsValid {
+				t.Fatalf("событие не распознано: %q", tt.line)
+			}
+			if event.Description != tt.wantDescr {
+				t.Errorf("Description = %q, ожидалось %q", event.Description, tt.wantDescr)
+			}
+			if !reflect.DeepEqual(event.LeadTimes, tt.wantLead) {
+				t.Errorf("LeadTimes = %v, ожидалось %v", event.LeadTimes, tt.wantLead)
+			}
+		})
+	}
+}
+
+func TestDueLeadTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      Clock
+		lead     []int
+		defaults []int
+		wantDue  int
+		wantOK   bool
+	}{
+		{name: "too early", now: clockAt(2026, time.March, 1, 9), wantOK: false},
+		{name: "first offset reached", now: clockAt(2026, time.March, 8, 9), wantDue: 7, wantOK: true},
+		{name: "between offsets", now: clockAt(2026, time.March, 11, 9), wantDue: 7, wantOK: true},
+		{name: "second offset reached", now: clockAt(2026, time.March, 14, 9), wantDue: 1, wantOK: true},
+		{name: "missed run catches up with nearest offset", now: clockAt(2026, time.March, 15, 9), wantDue: 1, wantOK: true},
+		{name: "event passed", now: clockAt(2026, time.March, 16, 9), wantOK: false},
+		{name: "defaults used without markers", now: clockAt(2026, time.March, 13, 9), lead: []int{}, defaults: []int{7, 3, 1, 0}, wantDue: 3, wantOK: true},
+		{name: "day of event stage", now: clockAt(2026, time.March, 15, 9), lead: []int{}, defaults: []int{7, 3, 1, 0}, wantDue: 0, wantOK: true},
+		{name: "markers override defaults", now: clockAt(2026, time.March, 13, 9), defaults: []int{3}, wantDue: 7, wantOK: true},
+		{name: "no markers and no defaults", now: clockAt(2026, time.March, 14, 9), lead: []int{}, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lead := tt.lead
+			if lead == nil {
+				lead = []int{7, 1}
+			}
+			p := NewParser(tt.now)
+			event := &EventEntry{
+				Date:        date(2026, time.March, 15),
+				EndDate:     date(2026, time.March, 15),
+				Description: "День рождения",
+				IsValid:     true,
+				LeadTimes:   lead,
+			}
+
+			due, ok := p.DueLeadTime(event, tt.defaults)
+			if ok != tt.wantOK || due != tt.wantDue {
+				t.Errorf("DueLeadTime = (%d, %v), ожидалось (%d, %v)", due, ok, tt.wantDue, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+)
+
+// adminCommands команды управления ботом, доступные администраторам группы.
+var adminCommands = []commandInfo{
+	{name: "recipients"},
+	{name: "mute"},
+	{name: "unmute"},
+	{name: "runnow"},
+	{name: "preview"},
+	{name: "forgetpin"},
+}
+
+// commandError ошибка, текст которой можно показать пользователю как есть.
+type commandError string
+
+func (e commandError) Error() string {
+	return string(e)
+}
+
+// adminSources оставляет группы из sources, в которых пользователь является
+// создателем или администратором. Ошибка возвращается, только если таких
+// групп нет и статус хотя бы в одной группе получить не удалось.
+func (f *Forwarder) adminSources(userID int64, sources []Source) ([]Source, error) {
+	var allowed []Source
+	var lastErr error
+	for _, source := range sources {
+		member, err := f.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+			ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
+				ChatID: source.ChatID,
+				UserID: userID,
+			},
+		})
+		if err != nil {
+			lastErr = fmt.Errorf("не удалось получить статус пользователя в группе %d: %w", source.ChatID, err)
+			continue
+		}
+		if member.IsCreator() || member.IsAdministrator() {
+			allowed = append(allowed, source)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, lastErr
+	}
+	return allowed, nil
+}
+
+// handleAdminCommand проверяет права администратора, выполняет команду
+// и записывает результат в журнал аудита. Команда действует только на группы,
+// которые администрирует пользователь, и на их получателей.
+func (f *Forwarder) handleAdminCommand(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale) (string, error) {
+	sources, err := f.adminSources(msg.From.ID, f.sourcesForChat(msg.Chat))
+	if err != nil {
+		return "", err
+	}
+	if len(sources) == 0 {
+		log.Printf("Пользователь %d не является администратором группы, команда /%s отклонена", msg.From.ID, msg.Command())
+		return loc.T("command.admin_only"), nil
+	}
+
+	var reply string
+	switch msg.Command() {
+	case "recipients":
+		reply, err = f.handleRecipients(ctx, loc, sources)
+	case "mute":
+		reply, err = f.handleSetAllowSending(ctx, msg, loc, sources, false)
+	case "unmute":
+		reply, err = f.handleSetAllowSending(ctx, msg, loc, sources, true)
+	case "runnow":
+		reply, err = f.handleRunNow(ctx, msg, loc, sources)
+	case "preview":
+		reply, err = f.handlePreview(ctx, msg.Chat.ID, loc, sources)
+	case "forgetpin":
+		reply, err = f.handleForgetPin(ctx, msg, loc)
+	}
+
+	result := "ok"
+	if err != nil {
+		result = err.Error()
+	}
+	action := database.AdminAction{
+		AdminUserID:   msg.From.ID,
+		AdminUsername: msg.From.UserName,
+		ChatID:        msg.Chat.ID,
+		Command:       msg.Command(),
+		Arguments:     msg.CommandArguments(),
+		Result:        result,
+	}
+	if auditErr := f.repository.LogAdminAction(ctx, action); auditErr != nil {
+		log.Printf("Ошибка при записи действия администратора: %v", auditErr)
+	}
+
+	var cmdErr commandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Error(), nil
+	}
+	return reply, err
+}
+
+func (f *Forwarder) handleRecipients(ctx context.Context, loc *locale.Locale, sources []Source) (string, error) {
+	recipients, err := f.repository.GetAllRecipients(ctx)
+	if err != nil {
+		return "", err
+	}
+	recipients = f.recipientsForSources(sources, recipients)
+	if len(recipients) == 0 {
+		return loc.T("recipients.none"), nil
+	}
+
+	var b strings.Builder
+	b.WriteString(loc.T("recipients.header", len(recipients)))
+	b.WriteString("\n")
+	for _, recipient := range recipients {
+		b.WriteString("\n")
+		switch {
+		case !recipient.IsActive:
+			b.WriteString("⛔ ")
+		case !recipient.AllowSending:
+			b.WriteString("🔇 ")
+		default:
+			b.WriteString("✅ ")
+		}
+
+		fmt.Fprintf(&b, "%d", recipient.UserID)
+		if recipient.Username != "" {
+			fmt.Fprintf(&b, " @%s", recipient.Username)
+		}
+		if !recipient.IsActive && recipient.DeactivationReason != nil {
+			b.WriteString(loc.T("recipients.deactivated", deactivationReason(loc, *recipient.DeactivationReason)))
+		}
+		if recipient.LastSentAt != nil {
+			b.WriteString(loc.T("recipients.last_sent", recipient.LastSentAt.Format("02.01.2006 15:04")))
+		}
+	}
+
+	return b.String(), nil
+}
+
+func (f *Forwarder) handleSetAllowSending(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale, sources []Source, allow bool) (string, error) {
+	recipient, err := f.findRecipient(ctx, loc, msg.CommandArguments(), sources)
+	if err != nil {
+		return "", err
+	}
+
+	if err := f.repository.SetAllowSending(ctx, recipient.UserID, allow); err != nil {
+		return "", err
+	}
+
+	if allow {
+		log.Printf("Администратор %d включил напоминания пользователю %d", msg.From.ID, recipient.UserID)
+		return loc.T("mute.unmuted", recipient.UserID), nil
+	}
+	log.Printf("Администратор %d отключил напоминания пользователю %d", msg.From.ID, recipient.UserID)
+	return loc.T("mute.muted", recipient.UserID), nil
+}
+
+// findRecipient ищет получателя групп sources по user_id или @username из
+// аргумента команды. Получатели других групп считаются ненайденными.
+func (f *Forwarder) findRecipient(ctx context.Context, loc *locale.Locale, arg string, sources []Source) (*database.Recipient, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return nil, commandError(loc.T("recipient.missing"))
+	}
+
+	var recipient *database.Recipient
+	var err error
+	if userID, parseErr := strconv.ParseInt(arg, 10, 64); parseErr == nil {
+		recipient, err = f.repository.GetRecipientByUserID(ctx, userID)
+	} else {
+		recipient, err = f.repository.GetRecipientByUsername(ctx, strings.TrimPrefix(arg, "@"))
+	}
+
+	if errors.Is(err, database.ErrRecipientNotFound) {
+		return nil, commandError(loc.T("recipient.not_found", arg))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(f.recipientsForSources(sources, []*database.Recipient{recipient})) == 0 {
+		return nil, commandError(loc.T("recipient.not_found", arg))
+	}
+	return recipient, nil
+}
+
+// deactivationReason причина отключения получателя на языке loc. Причины,
+// которых нет в каталоге сообщений, выводятся как сохранены.
+func deactivationReason(loc *locale.Locale, reason string) string {
+	key := "recipients.reason." + reason
+	if text := loc.T(key); text != key {
+		return text
+	}
+	return reason
+}
+
+// handleRunNow запускает рассылку в фоне, чтобы не задерживать обработку
+// других обновлений, и сообщает о результате отдельным сообщением.
+func (f *Forwarder) handleRunNow(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale, sources []Source) (string, error) {
+	chatID := msg.Chat.ID
+	log.Printf("Администратор %d запустил рассылку вручную", msg.From.ID)
+
+	go func() {
+		for _, source := range sources {
+			if err := f.ForwardPinnedMessage(ctx, source.ChatID); err != nil {
+				log.Printf("Ошибка при отправке напоминаний группы %d: %v", source.ChatID, err)
+				f.reply(ctx, chatID, loc.T("runnow.failed", source.title(loc)))
+				continue
+			}
+			f.reply(ctx, chatID, loc.T("runnow.done", source.title(loc)))
+		}
+	}()
+
+	return loc.T("runnow.started"), nil
+}
+
+// handlePreview показывает напоминание, которое уйдет при следующем запуске,
+// на языке администратора. Каждая часть длинного напоминания отправляется
+// отдельным сообщением, как при рассылке, поэтому ответ отправляется здесь
+// же и возвращается пустая строка. Состояние отправленных напоминаний не
+// меняется.
+func (f *Forwarder) handlePreview(ctx context.Context, chatID int64, loc *locale.Locale, sources []Source) (string, error) {
+	var previews []string
+	for _, source := range sources {
+		events, _, err := f.loadEvents(ctx, []Source{source})
+		if err != nil {
+			return "", err
+		}
+
+		var pending []dueStage
+		for _, d := range f.collectDueStages(source, events) {
+			isSent, err := f.repository.IsEventSent(ctx, source.ChatID, d.hash, d.stage)
+			if err != nil {
+				return "", err
+			}
+			if !isSent {
+				pending = append(pending, d)
+			}
+		}
+
+		if len(pending) > 0 {
+			previews = append(previews, loc.T("preview.header", source.title(loc)))
+			for _, part := range f.buildReminderParts(source, pending, loc) {
+				previews = append(previews, part.text)
+			}
+		}
+	}
+
+	if len(previews) == 0 {
+		var data MessageData
+		if len(sources) == 1 {
+			data.Source = sources[0].title(loc)
+		}
+		return f.templates.forLocale(loc).renderEmpty(data), nil
+	}
+
+	for _, preview := range previews {
+		f.reply(ctx, chatID, preview)
+	}
+	return "", nil
+}
@@ -0,0 +1,187 @@
+package telegram
+
+import (
+	"unicode"
+
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+// maxMessageLength предел длины текста сообщения Telegram в единицах UTF-16.
+const maxMessageLength = 4096
+
+// headerShare какую долю предела длины может занимать заголовок части.
+// Более длинный заголовок обрезается, чтобы для событий оставалось место.
+const headerShare = 4
+
+// textBlock фрагмент сообщения, который по возможности не разрывается
+// между частями, например строка одного события.
+type textBlock struct {
+	text     string
+	entities []parser.Entity
+}
+
+// messageChunk часть сообщения. blocks номера блоков, которые заканчиваются
+// в этой части.
+type messageChunk struct {
+	text     string
+	entities []parser.Entity
+	blocks   []int
+}
+
+// splitMessage собирает сообщение из заголовка и блоков, каждый с новой
+// строки. Если текст длиннее limit, он делится на части по границам блоков,
+// и header(part, total) получает номер части; для единственной части total
+// равен 1. Блок, который не помещается в часть целиком, режется по пробелу
+// вне entities. Заголовок длиннее limit/headerShare обрезается.
+func splitMessage(header func(part, total int) string, blocks []textBlock, limit int) []messageChunk {
+	fullHeader := header
+	header = func(part, total int) string {
+		return truncateUTF16(fullHeader(part, total), limit/headerShare)
+	}
+
+	used := header(1, 1)
+	chunks := groupBlocks(used, blocks, limit)
+	// С номером заголовок длиннее, поэтому части пересобираются, пока
+	// их количество не перестанет расти.
+	for total := 1; len(chunks) > total; {
+		total = len(chunks)
+		used = header(total, total)
+		chunks = groupBlocks(used, blocks, limit)
+	}
+
+	if len(chunks) == 1 {
+		return chunks
+	}
+	for i := range chunks {
+		numbered := header(i+1, len(chunks))
+		chunks[i].text = numbered + chunks[i].text[len(used):]
+		chunks[i].entities = parser.ShiftEntities(chunks[i].entities, parser.UTF16Len(numbered)-parser.UTF16Len(used))
+	}
+	return chunks
+}
+
+// groupBlocks раскладывает блоки по частям, начиная каждую с header.
+func groupBlocks(header string, blocks []textBlock, limit int) []messageChunk {
+	headerLen := parser.UTF16Len(header)
+	var chunks []messageChunk
+	current := messageChunk{text: header}
+	currentLen := headerLen
+
+	for i, block := range blocks {
+		pieces := splitBlock(block, limit-headerLen-1)
+		for j, piece := range pieces {
+			pieceLen := 1 + parser.UTF16Len(piece.text)
+			if currentLen > headerLen && currentLen+pieceLen > limit {
+				chunks = append(chunks, current)
+				current = messageChunk{text: header}
+				currentLen = headerLen
+			}
+
+			current.entities = append(current.entities, parser.ShiftEntities(piece.entities, currentLen+1)...)
+			current.text += "\n" + piece.text
+			currentLen += pieceLen
+			if j == len(pieces)-1 {
+				current.blocks = append(current.blocks, i)
+			}
+		}
+	}
+
+	return append(chunks, current)
+}
+
+// truncateUTF16 обрезает s до limit единиц UTF-16 по границе символа.
+func truncateUTF16(s string, limit int) string {
+	units := 0
+	for i, r := range s {
+		units++
+		if r >= 0x10000 {
+			units++
+		}
+		if units > limit {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// runeBoundary граница между символами текста.
+type runeBoundary struct {
+	// units смещение в единицах UTF-16, bytes в байтах.
+	units int
+	bytes int
+	// afterSpace перед границей стоит пробельный символ.
+	afterSpace bool
+}
+
+// splitBlock режет блок длиннее limit на куски. Разрез делается после
+// пробела вне entities, а если такого нет — в любом месте вне entities.
+func splitBlock(block textBlock, limit int) []textBlock {
+	if limit < 1 || parser.UTF16Len(block.text) <= limit {
+		return []textBlock{block}
+	}
+
+	bounds := []runeBoundary{{}}
+	units := 0
+	for i, r := range block.text {
+		units++
+		if r >= 0x10000 {
+			units++
+		}
+		bounds = append(bounds, runeBoundary{units: units, bytes: i + len(string(r)), afterSpace: unicode.IsSpace(r)})
+	}
+
+	var pieces []textBlock
+	start := 0
+	for bounds[len(bounds)-1].units-bounds[start].units > limit {
+		cut := findCut(bounds, block.entities, start, limit)
+		pieces = append(pieces, sliceBlock(block, bounds[start], bounds[cut]))
+		start = cut
+	}
+	return append(pieces, sliceBlock(block, bounds[start], bounds[len(bounds)-1]))
+}
+
+// findCut выбирает границу, на которой заканчивается кусок, начатый на
+// границе start.
+func findCut(bounds []runeBoundary, entities []parser.Entity, start, limit int) int {
+	last := start + 1
+	for last+1 < len(bounds) && bounds[last+1].units-bounds[start].units <= limit {
+		last++
+	}
+
+	insideEntity := func(units int) bool {
+		for _, e := range entities {
+			if e.Offset < units && units < e.Offset+e.Length {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := last; i > start; i-- {
+		if bounds[i].afterSpace && !insideEntity(bounds[i].units) {
+			return i
+		}
+	}
+	for i := last; i > start; i-- {
+		if !insideEntity(bounds[i].units) {
+			return i
+		}
+	}
+	// Entity длиннее части: ее придется разрезать.
+	return last
+}
+
+// sliceBlock кусок блока между границами from и to с обрезанными entities.
+func sliceBlock(block textBlock, from, to runeBoundary) textBlock {
+	piece := textBlock{text: block.text[from.bytes:to.bytes]}
+	for _, e := range block.entities {
+		start, end := max(e.Offset, from.units), min(e.Offset+e.Length, to.units)
+		if start >= end {
+			continue
+		}
+		e.Offset = start - from.units
+		e.Length = end - start
+		piece.entities = append(piece.entities, e)
+	}
+	return piece
+}
@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+func numberedHeader(title string) func(part, total int) string {
+	return func(part, total int) string {
+		if total == 1 {
+			return title
+		}
+		return fmt.Sprintf("%s (%d/%d)", title, part, total)
+	}
+}
+
+func eventBlocks(n, length int) []textBlock {
+	blocks := make([]textBlock, n)
+	for i := range blocks {
+		text := fmt.Sprintf("%02d ", i) + strings.Repeat("я", length-3)
+		blocks[i] = textBlock{text: text, entities: []parser.Entity{{Type: "bold", Offset: 0, Length: 2}}}
+	}
+	return blocks
+}
+
+func TestSplitMessage(t *testing.T) {
+	longWords := strings.TrimSpace(strings.Repeat("слово ", 50))
+
+	tests := []struct {
+		name       string
+		header     func(part, total int) string
+		blocks     []textBlock
+		limit      int
+		wantTexts  []string
+		wantBlocks [][]int
+		wantParts  int
+		// unnumbered заголовок обрезан вместе с номером части.
+		unnumbered bool
+	}{
+		{
+			name:       "single part stays unnumbered",
+			header:     numberedHeader("Напоминание"),
+			blocks:     []textBlock{{text: "первое"}, {text: "второе"}},
+			limit:      100,
+			wantTexts:  []string{"Напоминание\nпервое\nвторое"},
+			wantBlocks: [][]int{{0, 1}},
+		},
+		{
+			name:       "parts split on event boundaries",
+			header:     numberedHeader("Заголовок"),
+			blocks:     eventBlocks(4, 30),
+			limit:      80,
+			wantBlocks: [][]int{{0, 1}, {2, 3}},
+			wantParts:  2,
+		},
+		{
+			name:      "oversized event is split after a space",
+			header:    numberedHeader("Заголовок"),
+			blocks:    []textBlock{{text: longWords, entities: []parser.Entity{{Type: "italic", Offset: 0, Length: 5}}}},
+			limit:     100,
+			wantParts: 4,
+		},
+		{
+			name:      "long reminder fits telegram limit",
+			header:    numberedHeader("🎉 Напоминание о предстоящих событиях"),
+			blocks:    eventBlocks(300, 40),
+			limit:     maxMessageLength,
+			wantParts: 4,
+		},
+		{
+			name:       "header longer than the limit is truncated",
+			header:     numberedHeader(strings.Repeat("з", 200)),
+			blocks:     eventBlocks(3, 30),
+			limit:      100,
+			wantParts:  2,
+			unnumbered: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitMessage(tt.header, tt.blocks, tt.limit)
+
+			if tt.wantTexts != nil {
+				var texts []string
+				for _, c := range chunks {
+					texts = append(texts, c.text)
+				}
+				if !reflect.DeepEqual(texts, tt.wantTexts) {
+					t.Fatalf("части = %q, ожидалось %q", texts, tt.wantTexts)
+				}
+			}
+			if tt.wantParts != 0 && len(chunks) != tt.wantParts {
+				t.Fatalf("получено частей %d, ожидалось %d", len(chunks), tt.wantParts)
+			}
+			if tt.wantBlocks != nil {
+				var blocks [][]int
+				for _, c := range chunks {
+					blocks = append(blocks, c.blocks)
+				}
+				if !reflect.DeepEqual(blocks, tt.wantBlocks) {
+					t.Errorf("блоки по частям = %v, ожидалось %v", blocks, tt.wantBlocks)
+				}
+			}
+
+			for i, c := range chunks {
+				length := parser.UTF16Len(c.text)
+				if length > tt.limit {
+					t.Errorf("часть %d длиной %d больше предела %d", i+1, length, tt.limit)
+				}
+				if len(chunks) > 1 && !tt.unnumbered && !strings.Contains(c.text, fmt.Sprintf("(%d/%d)", i+1, len(chunks))) {
+					t.Errorf("часть %d без номера: %q", i+1, c.text)
+				}
+				for _, e := range c.entities {
+					if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > length {
+						t.Errorf("часть %d: entity %+v вне текста длиной %d", i+1, e, length)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessageKeepsEntityPositions(t *testing.T) {
+	blocks := eventBlocks(5, 30)
+	chunks := splitMessage(numberedHeader("Заголовок"), blocks, 80)
+
+	for i, c := range chunks {
+		units := parser.UTF16Len(c.text)
+		for _, e := range c.entities {
+			if e.Offset+e.Length > units {
+				t.Fatalf("часть %d: entity %+v вне текста", i+1, e)
+			}
+			got := string(utf16Slice(c.text, e.Offset, e.Offset+e.Length))
+			if len(got) != 2 || got[0] < '0' || got[0] > '9' {
+				t.Errorf("часть %d: жирным выделено %q, ожидался номер события", i+1, got)
+			}
+		}
+	}
+}
+
+// utf16Slice фрагмент текста между смещениями в единицах UTF-16.
+func utf16Slice(s string, from, to int) []byte {
+	units := 0
+	var out []byte
+	for _, r := range s {
+		if units >= from && units < to {
+			out = append(out, string(r)...)
+		}
+		units++
+		if r >= 0x10000 {
+			units++
+		}
+	}
+	return out
+}
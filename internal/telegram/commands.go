@@ -0,0 +1,247 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+// commandInfo команда бота. Описание берется из каталога сообщений по ключу
+// "command.<name>".
+type commandInfo struct {
+	name string
+}
+
+func (c commandInfo) description(loc *locale.Locale) string {
+	return loc.T("command." + c.name)
+}
+
+// subscriptionCommands команды подписки, доступные только в личном чате с ботом.
+var subscriptionCommands = []commandInfo{
+	{name: "subscribe"},
+	{name: "unsubscribe"},
+	{name: "status"},
+	{name: "language"},
+}
+
+// eventCommands команды со списком событий, доступные также в группе.
+var eventCommands = []commandInfo{
+	{name: "next"},
+	{name: "upcoming"},
+	{name: "today"},
+}
+
+// privateCommands все команды, доступные в личном чате с ботом.
+func privateCommands() []commandInfo {
+	return append(append([]commandInfo{}, subscriptionCommands...), eventCommands...)
+}
+
+// HandleUpdate обрабатывает входящее обновление от Telegram.
+func (f *Forwarder) HandleUpdate(ctx context.Context, update tgbotapi.Update) {
+	if update.EditedMessage != nil {
+		f.handleEditedMessage(ctx, update.EditedMessage)
+		return
+	}
+
+	msg := update.Message
+	if msg != nil && msg.PinnedMessage != nil {
+		f.handlePinnedMessage(ctx, msg)
+		return
+	}
+
+	if msg == nil || msg.From == nil || !msg.IsCommand() {
+		return
+	}
+
+	private := msg.Chat.IsPrivate()
+	if !private && (len(f.sourcesForChat(msg.Chat)) == 0 || !f.isAddressedToBot(msg)) {
+		return
+	}
+
+	log.Printf("Команда /%s от пользователя %d в чате %d", msg.Command(), msg.From.ID, msg.Chat.ID)
+	loc := f.messageLocale(ctx, msg)
+
+	var reply string
+	var err error
+	switch msg.Command() {
+	case "start", "subscribe", "unsubscribe", "status", "language":
+		if !private {
+			reply = loc.T("command.private_only")
+			break
+		}
+		switch msg.Command() {
+		case "start", "subscribe":
+			reply, err = f.handleSubscribe(ctx, msg, loc)
+		case "unsubscribe":
+			reply, err = f.handleUnsubscribe(ctx, msg, loc)
+		case "status":
+			reply, err = f.handleStatus(ctx, msg, loc)
+		case "language":
+			reply, err = f.handleLanguage(ctx, msg, loc)
+		}
+	case "next":
+		reply, err = f.handleNext(ctx, msg, loc)
+	case "upcoming":
+		reply, err = f.handleUpcoming(ctx, msg, loc)
+	case "today":
+		reply, err = f.handleToday(ctx, msg, loc)
+	case "recipients", "mute", "unmute", "runnow", "preview", "forgetpin":
+		reply, err = f.handleAdminCommand(ctx, msg, loc)
+	default:
+		if !private {
+			return
+		}
+		reply = helpText(loc)
+	}
+
+	if err != nil {
+		log.Printf("Ошибка при обработке команды /%s от пользователя %d: %v", msg.Command(), msg.From.ID, err)
+		reply = loc.T("command.failed")
+	}
+
+	// Пустой ответ значит, что обработчик уже отправил его сам.
+	if reply != "" {
+		f.reply(ctx, msg.Chat.ID, reply)
+	}
+}
+
+// isAddressedToBot в группе команда вида /next@other_bot адресована другому боту.
+func (f *Forwarder) isAddressedToBot(msg *tgbotapi.Message) bool {
+	_, mention, found := strings.Cut(msg.CommandWithAt(), "@")
+	return !found || strings.EqualFold(mention, f.bot.Self.UserName)
+}
+
+func (f *Forwarder) handleSubscribe(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale) (string, error) {
+	userID := msg.From.ID
+
+	// Подписаться может только тот, кто получает напоминания хотя бы одной
+	// группы, иначе список событий закрытой группы увидел бы любой.
+	sources, err := f.memberSources(userID, f.sources)
+	if err != nil {
+		return "", err
+	}
+	if len(sources) == 0 {
+		log.Printf("Пользователь %d не состоит ни в одной группе, подписка отклонена", userID)
+		return loc.T("subscribe.not_member"), nil
+	}
+
+	if err := f.repository.UpsertRecipient(ctx, userID, msg.From.UserName); err != nil {
+		return "", err
+	}
+	if err := f.repository.ReactivateRecipient(ctx, userID); err != nil {
+		return "", err
+	}
+	if err := f.repository.SetAllowSending(ctx, userID, true); err != nil {
+		return "", err
+	}
+	// Язык по умолчанию берем из клиента Telegram, выбранный через /language не трогаем.
+	if clientLoc, ok := locale.Match(msg.From.LanguageCode); ok {
+		if err := f.repository.SetRecipientLanguageIfEmpty(ctx, userID, clientLoc.Code); err != nil {
+			return "", err
+		}
+	}
+
+	log.Printf("Пользователь %d (%s) подписался на напоминания", userID, msg.From.UserName)
+	return loc.T("subscribe.done") + "\n\n" + helpText(loc), nil
+}
+
+func (f *Forwarder) handleUnsubscribe(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale) (string, error) {
+	userID := msg.From.ID
+
+	if _, err := f.repository.GetRecipientByUserID(ctx, userID); err != nil {
+		if errors.Is(err, database.ErrRecipientNotFound) {
+			return loc.T("unsubscribe.not_subscribed"), nil
+		}
+		return "", err
+	}
+
+	if err := f.repository.SetAllowSending(ctx, userID, false); err != nil {
+		return "", err
+	}
+
+	log.Printf("Пользователь %d отписался от напоминаний", userID)
+	return loc.T("unsubscribe.done"), nil
+}
+
+func (f *Forwarder) handleStatus(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale) (string, error) {
+	recipient, err := f.repository.GetRecipientByUserID(ctx, msg.From.ID)
+	if err != nil {
+		if errors.Is(err, database.ErrRecipientNotFound) {
+			return loc.T("status.not_subscribed"), nil
+		}
+		return "", err
+	}
+
+	var b strings.Builder
+	if recipient.IsActive && recipient.AllowSending {
+		b.WriteString(loc.T("status.active"))
+	} else {
+		b.WriteString(loc.T("status.disabled"))
+	}
+
+	if recipient.LastSentAt != nil {
+		b.WriteString("\n")
+		b.WriteString(loc.T("status.last_sent", recipient.LastSentAt.Format("02.01.2006 15:04")))
+	}
+	if recipient.DeliveryStatus == database.DeliveryStatusFailed {
+		b.WriteString("\n")
+		b.WriteString(loc.T("status.last_failed"))
+	}
+
+	return b.String(), nil
+}
+
+func helpText(loc *locale.Locale) string {
+	var b strings.Builder
+	b.WriteString(loc.T("help.header"))
+	for _, cmd := range privateCommands() {
+		description := []rune(cmd.description(loc))
+		fmt.Fprintf(&b, "\n/%s — %s", cmd.name, strings.ToLower(string(description[:1]))+string(description[1:]))
+	}
+	return b.String()
+}
+
+// reply отправляет ответ на команду сразу, минуя очередь напоминаний.
+// Ответ длиннее лимита Telegram отправляется несколькими сообщениями.
+func (f *Forwarder) reply(ctx context.Context, chatID int64, text string) {
+	for _, part := range splitReply(text) {
+		if _, err := f.sendMessage(ctx, tgbotapi.NewMessage(chatID, part)); err != nil {
+			log.Printf("Ошибка при отправке ответа в чат %d: %v", chatID, err)
+			return
+		}
+	}
+}
+
+// splitReply делит длинный ответ на части по строкам. Первая строка ответа
+// повторяется в начале каждой части вместе с номером части.
+func splitReply(text string) []string {
+	if parser.UTF16Len(text) <= maxMessageLength {
+		return []string{text}
+	}
+
+	lines := strings.Split(text, "\n")
+	blocks := make([]textBlock, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		blocks = append(blocks, textBlock{text: line})
+	}
+	header := func(part, total int) string {
+		if total == 1 {
+			return lines[0]
+		}
+		return fmt.Sprintf("%s (%d/%d)", lines[0], part, total)
+	}
+
+	chunks := splitMessage(header, blocks, maxMessageLength)
+	parts := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		parts = append(parts, chunk.text)
+	}
+	return parts
+}
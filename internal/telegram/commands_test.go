@@ -0,0 +1,56 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+func TestSplitReply(t *testing.T) {
+	var lines []string
+	for i := 0; i < 300; i++ {
+		lines = append(lines, fmt.Sprintf("%03d %s", i, strings.Repeat("я", 36)))
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		wantParts int
+	}{
+		{name: "short reply is sent as is", text: "События на ближайшие 7 дней:\n\n" + strings.Join(lines[:3], "\n"), wantParts: 1},
+		{name: "long reply is split by lines", text: "События на ближайшие 366 дней:\n\n" + strings.Join(lines, "\n"), wantParts: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitReply(tt.text)
+			if len(parts) != tt.wantParts {
+				t.Fatalf("получено частей %d, ожидалось %d", len(parts), tt.wantParts)
+			}
+			if len(parts) == 1 {
+				if parts[0] != tt.text {
+					t.Errorf("ответ изменен: %q", parts[0])
+				}
+				return
+			}
+
+			header, _, _ := strings.Cut(tt.text, "\n")
+			var body []string
+			for i, part := range parts {
+				if length := parser.UTF16Len(part); length > maxMessageLength {
+					t.Errorf("часть %d длиной %d больше предела", i+1, length)
+				}
+				first, rest, _ := strings.Cut(part, "\n")
+				if want := fmt.Sprintf("%s (%d/%d)", header, i+1, len(parts)); first != want {
+					t.Errorf("заголовок части %d = %q, ожидалось %q", i+1, first, want)
+				}
+				body = append(body, rest)
+			}
+			if got, want := strings.Join(body, "\n"), strings.SplitN(tt.text, "\n", 2)[1]; got != want {
+				t.Error("строки ответа потеряны или переставлены")
+			}
+		})
+	}
+}
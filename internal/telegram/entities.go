@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+// messageContent возвращает текст сообщения и его форматирование. У фото
+// и документов список событий записан в подписи.
+func messageContent(msg *tgbotapi.Message) (string, []tgbotapi.MessageEntity) {
+	if msg.Text != "" {
+		return msg.Text, msg.Entities
+	}
+	return msg.Caption, msg.CaptionEntities
+}
+
+// encodeEntities сериализует форматирование для хранения в базе. Для
+// сообщения без форматирования возвращает nil.
+func encodeEntities(entities []tgbotapi.MessageEntity) ([]byte, error) {
+	if len(entities) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(entities)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации форматирования сообщения: %w", err)
+	}
+	return data, nil
+}
+
+// decodeEntities разбирает форматирование, сохраненное encodeEntities.
+func decodeEntities(data []byte) ([]tgbotapi.MessageEntity, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var entities []tgbotapi.MessageEntity
+	if err := json.Unmarshal(data, &entities); err != nil {
+		return nil, fmt.Errorf("ошибка разбора форматирования сообщения: %w", err)
+	}
+	return entities, nil
+}
+
+func toParserEntities(entities []tgbotapi.MessageEntity) []parser.Entity {
+	converted := make([]parser.Entity, 0, len(entities))
+	for _, e := range entities {
+		entity := parser.Entity{
+			Type:     e.Type,
+			Offset:   e.Offset,
+			Length:   e.Length,
+			URL:      e.URL,
+			Language: e.Language,
+		}
+		if e.User != nil {
+			entity.UserID = e.User.ID
+		}
+		converted = append(converted, entity)
+	}
+	return converted
+}
+
+func toMessageEntities(entities []parser.Entity) []tgbotapi.MessageEntity {
+	converted := make([]tgbotapi.MessageEntity, 0, len(entities))
+	for _, e := range entities {
+		entity := tgbotapi.MessageEntity{
+			Type:     e.Type,
+			Offset:   e.Offset,
+			Length:   e.Length,
+			URL:      e.URL,
+			Language: e.Language,
+		}
+		if e.UserID != 0 {
+			entity.User = &tgbotapi.User{ID: e.UserID}
+		}
+		converted = append(converted, entity)
+	}
+	return converted
+}
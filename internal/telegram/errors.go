@@ -0,0 +1,62 @@
+package telegram
+
+import (
+	"errors"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sendErrorKind класс ошибки отправки сообщения.
+type sendErrorKind string
+
+// Постоянные ошибки сохраняются как причина отключения получателя
+// в recipients.deactivation_reason.
+const (
+	// sendErrorTransient временная ошибка: сеть, 5xx, 429. Отправку стоит повторить.
+	sendErrorTransient sendErrorKind = "transient"
+	// sendErrorBlocked пользователь заблокировал бота.
+	sendErrorBlocked sendErrorKind = "blocked"
+	// sendErrorChatNotFound чат не существует или бот его не видит.
+	sendErrorChatNotFound sendErrorKind = "chat_not_found"
+	// sendErrorUserDeactivated аккаунт пользователя удален.
+	sendErrorUserDeactivated sendErrorKind = "user_deactivated"
+	// sendErrorNotStarted пользователь ни разу не запускал бота.
+	sendErrorNotStarted sendErrorKind = "not_started"
+)
+
+// classifySendError определяет по ответу Telegram, имеет ли смысл
+// повторять отправку.
+func classifySendError(err error) sendErrorKind {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return sendErrorTransient
+	}
+
+	message := strings.ToLower(apiErr.Message)
+	switch {
+	case strings.Contains(message, "bot was blocked by the user"),
+		strings.Contains(message, "bot was kicked"):
+		return sendErrorBlocked
+	case strings.Contains(message, "user is deactivated"):
+		return sendErrorUserDeactivated
+	case strings.Contains(message, "chat not found"):
+		return sendErrorChatNotFound
+	case strings.Contains(message, "can't initiate conversation"),
+		strings.Contains(message, "bot can't send messages to bots"):
+		return sendErrorNotStarted
+	}
+
+	return sendErrorTransient
+}
+
+// isPermanent повтор отправки с такой ошибкой не поможет.
+func (k sendErrorKind) isPermanent() bool {
+	return k != sendErrorTransient
+}
+
+// isEntityParseError Telegram не смог разобрать разметку сообщения.
+func isEntityParseError(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && strings.Contains(strings.ToLower(apiErr.Message), "can't parse entities")
+}
@@ -0,0 +1,80 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestClassifySendError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want sendErrorKind
+	}{
+		{
+			name: "blocked by user",
+			err:  &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"},
+			want: sendErrorBlocked,
+		},
+		{
+			name: "kicked from group",
+			err:  &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was kicked from the group chat"},
+			want: sendErrorBlocked,
+		},
+		{
+			name: "not started",
+			err:  &tgbotapi.Error{Code: 403, Message: "Forbidden: bot can't initiate conversation with a user"},
+			want: sendErrorNotStarted,
+		},
+		{
+			name: "user deactivated",
+			err:  &tgbotapi.Error{Code: 403, Message: "Forbidden: user is deactivated"},
+			want: sendErrorUserDeactivated,
+		},
+		{
+			name: "chat not found",
+			err:  &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"},
+			want: sendErrorChatNotFound,
+		},
+		{
+			name: "wrapped api error",
+			err:  fmt.Errorf("ошибка отправки: %w", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}),
+			want: sendErrorBlocked,
+		},
+		{
+			name: "too many requests",
+			err:  &tgbotapi.Error{Code: 429, Message: "Too Many Requests: retry after 5", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5}},
+			want: sendErrorTransient,
+		},
+		{
+			name: "server error",
+			err:  &tgbotapi.Error{Code: 502, Message: "Bad Gateway"},
+			want: sendErrorTransient,
+		},
+		{
+			name: "other bad request",
+			err:  &tgbotapi.Error{Code: 400, Message: "Bad Request: message is too long"},
+			want: sendErrorTransient,
+		},
+		{
+			name: "network error",
+			err:  errors.New("dial tcp: i/o timeout"),
+			want: sendErrorTransient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifySendError(tt.err)
+			if got != tt.want {
+				t.Fatalf("classifySendError() = %s, ожидалось %s", got, tt.want)
+			}
+			if got.isPermanent() != (tt.want != sendErrorTransient) {
+				t.Errorf("isPermanent() = %v для %s", got.isPermanent(), got)
+			}
+		})
+	}
+}
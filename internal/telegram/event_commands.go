@@ -0,0 +1,156 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+// maxUpcomingDays ограничение для /upcoming: не больше года вперед. Длинный
+// ответ reply делит на несколько сообщений.
+const maxUpcomingDays = 366
+
+// loadEvents читает и разбирает закрепленные списки событий групп sources
+// и объединяет их, запоминая группу каждого события. Группа, список которой
+// прочитать не удалось, пропускается. Состояние отправленных напоминаний
+// при этом не меняется.
+func (f *Forwarder) loadEvents(ctx context.Context, sources []Source) ([]*parser.EventEntry, map[*parser.EventEntry]Source, error) {
+	var events []*parser.EventEntry
+	sourceOf := make(map[*parser.EventEntry]Source)
+	var lastErr error
+	loaded := 0
+	for _, source := range sources {
+		messages, err := f.loadPinnedMessages(ctx, source.ChatID)
+		if err != nil {
+			log.Printf("Не удалось прочитать список событий группы %d: %v", source.ChatID, err)
+			lastErr = err
+			continue
+		}
+
+		sourceEvents, _ := f.parsePinnedMessages(messages)
+		for _, event := range sourceEvents {
+			sourceOf[event] = source
+		}
+		events = append(events, sourceEvents...)
+		loaded++
+	}
+
+	if loaded == 0 && lastErr != nil {
+		return nil, nil, lastErr
+	}
+	return events, sourceOf, nil
+}
+
+// eventSources группы, события которых видит автор команды: в группе это она
+// сама, в личных сообщениях — группы, напоминания которых он получает.
+func (f *Forwarder) eventSources(msg *tgbotapi.Message) ([]Source, error) {
+	sources := f.sourcesForChat(msg.Chat)
+	if !msg.Chat.IsPrivate() {
+		return sources, nil
+	}
+	return f.memberSources(msg.From.ID, sources)
+}
+
+func (f *Forwarder) handleNext(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale) (string, error) {
+	sources, err := f.eventSources(msg)
+	if err != nil {
+		return "", err
+	}
+	if len(sources) == 0 {
+		return loc.T("events.not_member"), nil
+	}
+
+	events, sourceOf, err := f.loadEvents(ctx, sources)
+	if err != nil {
+		return "", err
+	}
+
+	next := f.parser.GetNextEvents(events)
+	if len(next) == 0 {
+		return loc.T("next.none"), nil
+	}
+	return f.formatEventList(loc, loc.T("next.header"), next, sourceOf), nil
+}
+
+func (f *Forwarder) handleUpcoming(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale) (string, error) {
+	sources, err := f.eventSources(msg)
+	if err != nil {
+		return "", err
+	}
+	if len(sources) == 0 {
+		return loc.T("events.not_member"), nil
+	}
+
+	days := f.daysAhead
+	if len(sources) == 1 {
+		days = sources[0].DaysAhead
+	}
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 || n > maxUpcomingDays {
+			return loc.T("upcoming.usage", maxUpcomingDays), nil
+		}
+		days = n
+	}
+
+	events, sourceOf, err := f.loadEvents(ctx, sources)
+	if err != nil {
+		return "", err
+	}
+
+	upcoming := f.parser.GetUpcomingEvents(events, days)
+	if len(upcoming) == 0 {
+		return loc.T("upcoming.none", days, loc.PluralDays(days)), nil
+	}
+	return f.formatEventList(loc, loc.T("upcoming.header", days, loc.PluralDays(days)), upcoming, sourceOf), nil
+}
+
+func (f *Forwarder) handleToday(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale) (string, error) {
+	sources, err := f.eventSources(msg)
+	if err != nil {
+		return "", err
+	}
+	if len(sources) == 0 {
+		return loc.T("events.not_member"), nil
+	}
+
+	events, sourceOf, err := f.loadEvents(ctx, sources)
+	if err != nil {
+		return "", err
+	}
+
+	today := f.parser.GetUpcomingEvents(events, 0)
+	if len(today) == 0 {
+		return loc.T("today.none"), nil
+	}
+	return f.formatEventList(loc, loc.T("today.header"), today, sourceOf), nil
+}
+
+// formatEventList выводит события по возрастанию даты начала по шаблону строки
+// языка loc.
+func (f *Forwarder) formatEventList(loc *locale.Locale, header string, events []*parser.EventEntry, sourceOf map[*parser.EventEntry]Source) string {
+	templates := f.templates.forLocale(loc)
+	eventParser := f.parser.WithLocale(loc)
+
+	sorted := make([]*parser.EventEntry, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	var b strings.Builder
+	b.WriteString(header)
+	b.WriteString("\n")
+	for _, event := range sorted {
+		b.WriteString("\n")
+		line, _ := templates.renderLine(eventParser, event, sourceOf[event].title(loc))
+		b.WriteString(line)
+	}
+	return b.String()
+}
@@ -4,40 +4,153 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
 	"telegram_bot/telegram-pin-forwarder/internal/parser"
 )
 
+type Options struct {
+	// Sources группы с закрепленными списками событий. Команды со списком
+	// событий принимаются в личных сообщениях и в этих группах.
+	Sources []Source
+	// DaysAhead значение по умолчанию для групп без своего days_ahead и для /upcoming.
+	DaysAhead int
+	// ReminderStages за сколько дней до события напоминать, например [7, 3, 1, 0].
+	// Если пусто, напоминание отправляется один раз за DaysAhead дней.
+	ReminderStages []int
+	// SameDayHoursBefore за сколько часов до начала напоминать о событиях
+	// с указанным временем. 0 отключает такие напоминания.
+	SameDayHoursBefore int
+	// MaxSendAttempts сколько раз пытаться отправить сообщение из очереди,
+	// прежде чем перевести его в dead.
+	MaxSendAttempts int
+	// RetryBaseBackoff задержка перед второй попыткой; каждая следующая вдвое
+	// больше, но не больше RetryMaxBackoff.
+	RetryBaseBackoff time.Duration
+	RetryMaxBackoff  time.Duration
+	// MessagesPerSecond общий лимит отправки, PerChatInterval — минимальный
+	// интервал между сообщениями в один чат.
+	MessagesPerSecond int
+	PerChatInterval   time.Duration
+	// NotifyAdminsOnChange отправлять администраторам группы сводку при
+	// изменении закрепленного списка событий.
+	NotifyAdminsOnChange bool
+	// SendOnChange сразу запускать рассылку, когда в закрепленном списке
+	// появились новые события; иначе они попадут в ближайшую рассылку
+	// по расписанию.
+	SendOnChange bool
+	// PinHashtag если задан, из закрепленных сообщений читаются только
+	// помеченные этим хэштегом, например "#calendar".
+	PinHashtag string
+	// ParseMode разметка напоминаний: tgbotapi.ModeHTML, tgbotapi.ModeMarkdownV2
+	// или пустая строка, тогда форматирование передается через entities.
+	ParseMode string
+	// Templates шаблоны сообщений; nil — шаблоны по умолчанию.
+	Templates *Templates
+	// Locale язык сообщений по умолчанию: для получателей, не выбравших язык,
+	// и для ответов в группах; nil — русский.
+	Locale *locale.Locale
+	Clock  parser.Clock
+}
+
 type Forwarder struct {
-	bot        *tgbotapi.BotAPI
-	repository *database.Repository
-	daysAhead  int
-	token      string
+	bot                  *tgbotapi.BotAPI
+	repository           *database.Repository
+	sources              []Source
+	daysAhead            int
+	reminderStages       []int
+	sameDayHoursBefore   int
+	maxSendAttempts      int
+	retryBaseBackoff     time.Duration
+	retryMaxBackoff      time.Duration
+	token                string
+	clock                parser.Clock
+	parser               *parser.Parser
+	limiter              *rateLimiter
+	notifyAdminsOnChange bool
+	sendOnChange         bool
+	pinHashtag           string
+	parseMode            string
+	templates            *Templates
+	locale               *locale.Locale
+	outboxMu             sync.Mutex
+	// runMu не дает запускам по расписанию и по команде /runnow
+	// обрабатывать одни и те же этапы одновременно.
+	runMu sync.Mutex
 }
 
-func NewForwarder(token string, repo *database.Repository, daysAhead int) (*Forwarder, error) {
+// maxRateLimitRetries сколько раз повторять отправку после ответа 429,
+// прежде чем вернуть ошибку в очередь.
+const maxRateLimitRetries = 3
+
+func NewForwarder(token string, repo *database.Repository, opts Options) (*Forwarder, error) {
 	bot, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось создать бота: %w", err)
 	}
 
 	log.Printf("Авторизован как %s", bot.Self.UserName)
+	clock := opts.Clock
+	if clock == nil {
+		clock = parser.SystemClock{}
+	}
+
+	loc := opts.Locale
+	if loc == nil {
+		loc = locale.Russian
+	}
+
+	templates := opts.Templates
+	if templates == nil {
+		templates = defaultTemplates()
+	}
+
+	sources := make([]Source, len(opts.Sources))
+	for i, source := range opts.Sources {
+		if source.DaysAhead < 1 {
+			source.DaysAhead = opts.DaysAhead
+		}
+		sources[i] = source
+	}
+
 	return &Forwarder{
-		bot:        bot,
-		repository: repo,
-		daysAhead:  daysAhead,
-		token:      token,
+		bot:                  bot,
+		repository:           repo,
+		sources:              sources,
+		daysAhead:            opts.DaysAhead,
+		reminderStages:       opts.ReminderStages,
+		sameDayHoursBefore:   opts.SameDayHoursBefore,
+		maxSendAttempts:      opts.MaxSendAttempts,
+		retryBaseBackoff:     opts.RetryBaseBackoff,
+		retryMaxBackoff:      opts.RetryMaxBackoff,
+		token:                token,
+		clock:                clock,
+		parser:               parser.NewParser(clock).WithLocale(loc),
+		limiter:              newRateLimiter(opts.MessagesPerSecond, opts.PerChatInterval, clock),
+		notifyAdminsOnChange: opts.NotifyAdminsOnChange,
+		sendOnChange:         opts.SendOnChange,
+		pinHashtag:           opts.PinHashtag,
+		parseMode:            opts.ParseMode,
+		templates:            templates,
+		locale:               loc,
 	}, nil
 }
 
+// errNoPinnedMessage Telegram ответил, что в чате нет закрепленного сообщения.
+var errNoPinnedMessage = errors.New("нет закрепленного сообщения")
+
+// GetPinnedMessage возвращает последнее закрепленное сообщение группы. Если
+// Telegram ответил, что закрепленного сообщения нет, ошибка оборачивает
+// errNoPinnedMessage; остальные ошибки означают, что ответ получить не удалось.
 func (f *Forwarder) GetPinnedMessage(chatID int64) (*tgbotapi.Message, error) {
 	chatConfig := tgbotapi.ChatInfoConfig{
 		ChatConfig: tgbotapi.ChatConfig{
@@ -60,13 +173,13 @@ func (f *Forwarder) GetPinnedMessage(chatID int64) (*tgbotapi.Message, error) {
 
 	log.Println("Поле PinnedMessage пустое в GetChat, пытаемся получить через прямой HTTP запрос...")
 
-	pinnedMsg, err := f.getPinnedMessageViaHTTP(chatID)
-	if err == nil && pinnedMsg != nil {
+	pinnedMsg, httpErr := f.getPinnedMessageViaHTTP(chatID)
+	if httpErr == nil && pinnedMsg != nil {
 		log.Println("Закрепленное сообщение найдено через прямой HTTP запрос")
 		return pinnedMsg, nil
 	}
-	if err != nil {
-		log.Printf("Ошибка при получении через HTTP: %v", err)
+	if httpErr != nil {
+		log.Printf("Ошибка при получении через HTTP: %v", httpErr)
 	}
 
 	log.Println("Попытка получить информацию о чате через альтернативный метод...")
@@ -88,73 +201,64 @@ func (f *Forwarder) GetPinnedMessage(chatID int64) (*tgbotapi.Message, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("в чате %d не найдено закрепленного сообщения. Убедитесь, что: 1) сообщение закреплено в группе, 2) бот является участником и имеет права на чтение", chatID)
+	if !errors.Is(httpErr, errNoPinnedMessage) {
+		return nil, fmt.Errorf("не удалось получить закрепленное сообщение чата %d: %v", chatID, httpErr)
+	}
+	return nil, fmt.Errorf("в чате %d %w. Убедитесь, что: 1) сообщение закреплено в группе, 2) бот является участником и имеет права на чтение", chatID, errNoPinnedMessage)
 }
 
 func (f *Forwarder) ForwardPinnedMessage(ctx context.Context, groupChatID int64) error {
-	log.Printf("Получаем закрепленное сообщение из группы %d...", groupChatID)
-
-	pinnedMessage, err := f.GetPinnedMessage(groupChatID)
-	if err != nil {
-		return err
+	source, ok := f.source(groupChatID)
+	if !ok {
+		return fmt.Errorf("группа %d не указана в списке источников", groupChatID)
 	}
 
-	if pinnedMessage.Text == "" {
-		return fmt.Errorf("закрепленное сообщение не содержит текста")
-	}
+	f.runMu.Lock()
+	defer f.runMu.Unlock()
 
-	log.Println("Закрепленное сообщение найдено!")
-	log.Printf("Парсим список событий (проверяем события в течение %d дней)...", f.daysAhead)
+	log.Printf("Получаем закрепленные сообщения из группы %d (%s)...", groupChatID, source.title(f.locale))
 
-	events := parser.ParseEventList(pinnedMessage.Text)
-	log.Printf("Распарсено событий: %d", len(events))
+	pinnedMessages, err := f.loadPinnedMessages(ctx, groupChatID)
+	if err != nil {
+		return err
+	}
 
-	upcomingEvents := parser.GetUpcomingEvents(events, f.daysAhead)
+	log.Printf("Закрепленных сообщений со списком событий: %d", len(pinnedMessages))
 
-	if len(upcomingEvents) == 0 {
-		log.Println("Нет предстоящих событий в течение указанного периода")
-		return nil
+	// Изменения, пропущенные, пока бот не получал обновления
+	if diff, err := f.syncPinnedSnapshot(ctx, groupChatID, pinnedMessages); err != nil {
+		log.Printf("Ошибка при обновлении снимка закрепленного сообщения: %v", err)
+	} else if !diff.isEmpty() {
+		f.reportEventListChanges(ctx, groupChatID, diff)
 	}
 
-	log.Printf("Найдено предстоящих событий: %d", len(upcomingEvents))
+	log.Printf("Парсим список событий (проверяем события в течение %d дней)...", source.DaysAhead)
 
-	var newEvents []*parser.EventEntry
-	for _, event := range upcomingEvents {
-		eventHash := database.GenerateEventHash(event.Date, event.Description)
-		isSent, err := f.repository.IsEventSent(ctx, eventHash)
-		if err != nil {
-			log.Printf("Ошибка при проверке отправленного события: %v", err)
-			continue
-		}
-		if isSent {
-			log.Printf("Событие уже было отправлено: %s - %s", event.Date.Format("2006-01-02"), event.Description)
-			continue
+	events, sourceIDs := f.parsePinnedMessages(pinnedMessages)
+	log.Printf("Распарсено событий: %d", len(events))
+	for _, event := range events {
+		if event.Expired {
+			log.Printf("Событие с явно указанным годом уже прошло: %s", event.RawDate)
 		}
-		newEvents = append(newEvents, event)
 	}
 
-	if len(newEvents) == 0 {
-		log.Println("Все предстоящие события уже были отправлены ранее")
-		return nil
+	if err := f.storeEvents(ctx, groupChatID, events, sourceIDs); err != nil {
+		log.Printf("Ошибка при сохранении событий: %v", err)
 	}
 
-	log.Printf("Новых событий для отправки: %d", len(newEvents))
-
-	eventMessages := make([]string, 0)
-	for _, event := range newEvents {
-		formatted := parser.FormatEventForMessage(event)
-		if formatted != "" {
-			eventMessages = append(eventMessages, formatted)
-		}
-		log.Printf("  - %s", event.RawDate)
+	due := f.collectDueStages(source, events)
+	if len(due) == 0 {
+		log.Println("Нет предстоящих событий, о которых пора напомнить")
+		return nil
 	}
 
-	messageText := "🎉 Напоминание о предстоящих событиях:\n\n" + strings.Join(eventMessages, "\n")
+	log.Printf("Наступивших этапов напоминаний: %d", len(due))
 
 	recipients, err := f.repository.GetActiveRecipients(ctx)
 	if err != nil {
 		return fmt.Errorf("ошибка получения списка получателей: %w", err)
 	}
+	recipients = f.recipientsForSource(source, recipients)
 
 	if len(recipients) == 0 {
 		log.Println("Нет активных получателей с разрешением на отправку")
@@ -166,40 +270,106 @@ func (f *Forwarder) ForwardPinnedMessage(ctx context.Context, groupChatID int64)
 		log.Printf("  - Пользователь ID: %d, Username: %s", recipient.UserID, recipient.Username)
 	}
 
-	log.Printf("Отправляем напоминание %d получателям из БД...", len(recipients))
+	plans := f.planDeliveries(ctx, groupChatID, due, recipients)
+	if len(plans) == 0 {
+		log.Println("Все наступившие напоминания уже доставлены")
+		return nil
+	}
 
-	successCount := 0
-	for _, recipient := range recipients {
-		if err := f.sendMessage(recipient.UserID, messageText); err != nil {
-			log.Printf("Ошибка при отправке пользователю %d: %v", recipient.UserID, err)
-			errMsg := err.Error()
-			f.repository.UpdateDeliveryStatus(ctx, recipient.UserID, "failed", &errMsg)
-			continue
+	parts := make([][]reminderPart, len(plans))
+	totals := make(map[string]int)
+	for i, plan := range plans {
+		parts[i] = f.buildReminderParts(source, plan.stages, f.recipientLocale(plan.recipient))
+		for _, part := range parts[i] {
+			totals[part.text]++
 		}
+	}
 
-		log.Printf("Напоминание отправлено пользователю %d", recipient.UserID)
-		f.repository.UpdateDeliveryStatus(ctx, recipient.UserID, "success", nil)
-		successCount++
-		time.Sleep(500 * time.Millisecond)
+	// Для каждого уникального текста (или части длинного напоминания) один лог.
+	logIDs := make(map[string]*int64)
+	for _, planParts := range parts {
+		for _, part := range planParts {
+			if _, ok := logIDs[part.text]; ok {
+				continue
+			}
+			id, err := f.repository.CreateMessageLog(ctx, groupChatID, latestPinnedID(pinnedMessages), "event_reminder", part.text, totals[part.text], 0)
+			if err != nil {
+				log.Printf("Ошибка при создании лога сообщения: %v", err)
+				logIDs[part.text] = nil
+				continue
+			}
+			logIDs[part.text] = &id
+		}
 	}
 
-	if successCount > 0 {
-		for _, event := range newEvents {
-			eventHash := database.GenerateEventHash(event.Date, event.Description)
-			if err := f.repository.MarkEventAsSent(ctx, event.Date, event.Description, eventHash); err != nil {
-				log.Printf("Ошибка при сохранении информации об отправленном событии: %v", err)
-			} else {
-				log.Printf("Событие помечено как отправленное: %s - %s", event.Date.Format("2006-01-02"), event.Description)
+	queued := 0
+	for i, plan := range plans {
+		userID := plan.recipient.UserID
+		if len(parts[i]) > 1 {
+			log.Printf("Напоминание пользователю %d разделено на части: %d", userID, len(parts[i]))
+		}
+
+		ok := true
+		for _, part := range parts[i] {
+			keys := make([]database.DeliveryKey, 0, len(part.stages))
+			for _, d := range part.stages {
+				keys = append(keys, database.DeliveryKey{SourceChatID: groupChatID, EventHash: d.hash, Stage: d.stage})
+			}
+
+			entities, err := encodeEntities(toMessageEntities(part.entities))
+			if err != nil {
+				log.Printf("Напоминание пользователю %d будет отправлено без форматирования: %v", userID, err)
+			}
+			if _, err := f.repository.EnqueueMessage(ctx, userID, part.text, entities, logIDs[part.text], f.maxSendAttempts, keys); err != nil {
+				log.Printf("Ошибка при постановке напоминания в очередь для пользователя %d: %v", userID, err)
+				ok = false
 			}
 		}
+		if ok {
+			queued++
+		}
 	}
 
-	f.repository.CreateMessageLog(ctx, pinnedMessage.MessageID, "event_reminder", messageText, len(recipients), successCount)
+	log.Printf("Напоминаний поставлено в очередь отправки: %d/%d", queued, len(plans))
 
-	log.Printf("Готово! Напоминания отправлены: %d/%d", successCount, len(recipients))
+	if err := f.DrainOutbox(ctx); err != nil {
+		return fmt.Errorf("ошибка при отправке напоминаний из очереди: %w", err)
+	}
+
+	log.Println("Готово! Неотправленные напоминания будут повторены из очереди")
 	return nil
 }
 
+// storeEvents сохраняет распознанные события закрепленных сообщений
+// в таблицу events. sourceIDs указывает, из какого сообщения взято событие.
+func (f *Forwarder) storeEvents(ctx context.Context, sourceChatID int64, events []*parser.EventEntry, sourceIDs map[*parser.EventEntry]int) error {
+	stored := make([]*database.Event, 0, len(events))
+	for _, event := range events {
+		if !event.IsValid {
+			continue
+		}
+
+		var eventTime *string
+		if event.HasTime {
+			t := event.Date.Format("15:04")
+			eventTime = &t
+		}
+
+		stored = append(stored, &database.Event{
+			EventHash:       database.GenerateEventHash(event.Date, event.Description),
+			EventDate:       event.Date,
+			EndDate:         event.EndDate,
+			EventTime:       eventTime,
+			Description:     event.Description,
+			SourceMessageID: sourceIDs[event],
+		})
+	}
+
+	today := f.clock.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	return f.repository.SyncEvents(ctx, sourceChatID, stored, today)
+}
+
 func (f *Forwarder) getPinnedMessageViaHTTP(chatID int64) (*tgbotapi.Message, error) {
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/getChat", f.token)
 
@@ -261,7 +431,7 @@ func (f *Forwarder) getPinnedMessageViaHTTP(chatID int64) (*tgbotapi.Message, er
 	}
 
 	log.Printf("Поле pinned_message отсутствует в ответе API. Доступные поля: %v", getKeys(apiResponse.Result))
-	return nil, fmt.Errorf("закрепленное сообщение не найдено в ответе API")
+	return nil, errNoPinnedMessage
 }
 
 func getKeys(m map[string]interface{}) []string {
@@ -272,8 +442,22 @@ func getKeys(m map[string]interface{}) []string {
 	return keys
 }
 
-func (f *Forwarder) sendMessage(userID int64, text string) error {
-	msg := tgbotapi.NewMessage(userID, text)
-	_, err := f.bot.Send(msg)
-	return err
+// sendMessage отправляет сообщение с учетом лимитов Telegram. Если Telegram
+// ответил 429, отправка повторяется после retry_after.
+func (f *Forwarder) sendMessage(ctx context.Context, msg tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	chatID := msg.ChatID
+	for attempt := 0; ; attempt++ {
+		if err := f.limiter.Wait(ctx, chatID); err != nil {
+			return tgbotapi.Message{}, err
+		}
+
+		sent, err := f.bot.Send(msg)
+		wait, limited := retryAfter(err)
+		if !limited || attempt >= maxRateLimitRetries {
+			return sent, err
+		}
+
+		log.Printf("Превышен лимит Telegram при отправке в чат %d, ждем %s", chatID, wait)
+		f.limiter.Pause(wait)
+	}
 }
@@ -0,0 +1,85 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+)
+
+// recipientLocale язык, выбранный получателем, или язык по умолчанию.
+func (f *Forwarder) recipientLocale(recipient *database.Recipient) *locale.Locale {
+	if recipient == nil || recipient.Language == nil {
+		return f.locale
+	}
+	if loc, ok := locale.Get(*recipient.Language); ok {
+		return loc
+	}
+	return f.locale
+}
+
+// messageLocale язык ответа на команду. В личных сообщениях это язык,
+// выбранный пользователем, а если он не выбран — язык его клиента Telegram.
+// В группах отвечаем на языке по умолчанию.
+func (f *Forwarder) messageLocale(ctx context.Context, msg *tgbotapi.Message) *locale.Locale {
+	if !msg.Chat.IsPrivate() {
+		return f.locale
+	}
+
+	recipient, err := f.repository.GetRecipientByUserID(ctx, msg.From.ID)
+	switch {
+	case err == nil && recipient.Language != nil:
+		return f.recipientLocale(recipient)
+	case err != nil && !errors.Is(err, database.ErrRecipientNotFound):
+		log.Printf("Не удалось получить язык пользователя %d: %v", msg.From.ID, err)
+	}
+
+	if loc, ok := locale.Match(msg.From.LanguageCode); ok {
+		return loc
+	}
+	return f.locale
+}
+
+// availableLanguages перечисляет поддерживаемые языки для подсказок.
+func availableLanguages() string {
+	codes := locale.Codes()
+	names := make([]string, 0, len(codes))
+	for _, code := range codes {
+		loc, _ := locale.Get(code)
+		names = append(names, fmt.Sprintf("%s (%s)", code, loc.Name()))
+	}
+	return strings.Join(names, ", ")
+}
+
+// handleLanguage показывает язык сообщений или меняет его на указанный.
+// Язык хранится у получателя, поэтому сменить его можно только после
+// подписки. Ответ о смене языка отправляется уже на новом языке.
+func (f *Forwarder) handleLanguage(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale) (string, error) {
+	arg := strings.TrimSpace(msg.CommandArguments())
+	if arg == "" {
+		return loc.T("language.current", loc.Name(), availableLanguages()), nil
+	}
+
+	selected, ok := locale.Get(strings.ToLower(arg))
+	if !ok {
+		return loc.T("language.unknown", arg, availableLanguages()), nil
+	}
+
+	if _, err := f.repository.GetRecipientByUserID(ctx, msg.From.ID); err != nil {
+		if errors.Is(err, database.ErrRecipientNotFound) {
+			return loc.T("status.not_subscribed"), nil
+		}
+		return "", err
+	}
+	if err := f.repository.SetRecipientLanguage(ctx, msg.From.ID, selected.Code); err != nil {
+		return "", err
+	}
+
+	log.Printf("Пользователь %d выбрал язык сообщений %s", msg.From.ID, selected.Code)
+	return selected.T("language.changed", selected.Name()), nil
+}
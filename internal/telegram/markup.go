@@ -0,0 +1,178 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// markdownV2Special символы, которые в MarkdownV2 нужно экранировать в тексте.
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// renderMarkup переводит текст с entities в разметку parseMode (HTML или
+// MarkdownV2). Весь текст экранируется, поэтому описания событий со
+// спецсимволами не ломают разметку. Entities, пересекающие другие, и
+// типы, которые Telegram распознает сам (упоминания, ссылки, хэштеги),
+// выводятся как обычный текст.
+func renderMarkup(text string, entities []tgbotapi.MessageEntity, parseMode string) string {
+	sorted := make([]tgbotapi.MessageEntity, 0, len(entities))
+	for _, e := range entities {
+		if e.Length > 0 && e.Offset >= 0 && markupTags(e, parseMode) != nil {
+			sorted = append(sorted, e)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Offset != sorted[j].Offset {
+			return sorted[i].Offset < sorted[j].Offset
+		}
+		return sorted[i].Length > sorted[j].Length
+	})
+
+	var b strings.Builder
+	// В MarkdownV2 подряд идущие "_" и "__" неоднозначны ("___"), поэтому
+	// между такими тегами ставится \r, который Telegram пропускает.
+	afterUnderscoreTag := false
+	writeTag := func(tag string) {
+		if parseMode == tgbotapi.ModeMarkdownV2 && afterUnderscoreTag && strings.HasPrefix(tag, "_") {
+			b.WriteString("\r")
+		}
+		b.WriteString(tag)
+		afterUnderscoreTag = strings.HasSuffix(tag, "_")
+	}
+
+	var open []tgbotapi.MessageEntity
+	closeUntil := func(pos int) {
+		for len(open) > 0 && open[len(open)-1].Offset+open[len(open)-1].Length <= pos {
+			writeTag(markupTags(open[len(open)-1], parseMode)[1])
+			open = open[:len(open)-1]
+		}
+	}
+
+	pos, next := 0, 0
+	for _, r := range text {
+		closeUntil(pos)
+		for ; next < len(sorted) && sorted[next].Offset <= pos; next++ {
+			e := sorted[next]
+			if e.Offset < pos {
+				continue
+			}
+			if len(open) > 0 && e.Offset+e.Length > open[len(open)-1].Offset+open[len(open)-1].Length {
+				continue
+			}
+			writeTag(markupTags(e, parseMode)[0])
+			open = append(open, e)
+		}
+
+		b.WriteString(escapeMarkup(r, parseMode, inCode(open)))
+		afterUnderscoreTag = false
+		if r >= 0x10000 {
+			pos += 2
+		} else {
+			pos++
+		}
+	}
+	closeUntil(math.MaxInt)
+
+	return b.String()
+}
+
+// markupTags открывающий и закрывающий теги для entity или nil, если
+// entity не нужно размечать.
+func markupTags(e tgbotapi.MessageEntity, parseMode string) []string {
+	if parseMode == tgbotapi.ModeHTML {
+		switch e.Type {
+		case "bold":
+			return []string{"<b>", "</b>"}
+		case "italic":
+			return []string{"<i>", "</i>"}
+		case "underline":
+			return []string{"<u>", "</u>"}
+		case "strikethrough":
+			return []string{"<s>", "</s>"}
+		case "spoiler":
+			return []string{"<tg-spoiler>", "</tg-spoiler>"}
+		case "code":
+			return []string{"<code>", "</code>"}
+		case "pre":
+			if e.Language != "" {
+				return []string{fmt.Sprintf(`<pre><code class="language-%s">`, html.EscapeString(e.Language)), "</code></pre>"}
+			}
+			return []string{"<pre>", "</pre>"}
+		case "text_link":
+			return []string{fmt.Sprintf(`<a href="%s">`, html.EscapeString(e.URL)), "</a>"}
+		case "text_mention":
+			if e.User != nil {
+				return []string{fmt.Sprintf(`<a href="tg://user?id=%d">`, e.User.ID), "</a>"}
+			}
+		}
+		return nil
+	}
+
+	switch e.Type {
+	case "bold":
+		return []string{"*", "*"}
+	case "italic":
+		return []string{"_", "_"}
+	case "underline":
+		return []string{"__", "__"}
+	case "strikethrough":
+		return []string{"~", "~"}
+	case "spoiler":
+		return []string{"||", "||"}
+	case "code":
+		return []string{"`", "`"}
+	case "pre":
+		return []string{"```" + e.Language + "\n", "\n```"}
+	case "text_link":
+		return []string{"[", "](" + escapeMarkdownURL(e.URL) + ")"}
+	case "text_mention":
+		if e.User != nil {
+			return []string{"[", fmt.Sprintf("](tg://user?id=%d)", e.User.ID)}
+		}
+	}
+	return nil
+}
+
+// inCode текст выводится внутри code или pre, где в MarkdownV2
+// экранируются только ` и \.
+func inCode(open []tgbotapi.MessageEntity) bool {
+	for _, e := range open {
+		if e.Type == "code" || e.Type == "pre" {
+			return true
+		}
+	}
+	return false
+}
+
+func escapeMarkup(r rune, parseMode string, code bool) string {
+	if parseMode == tgbotapi.ModeHTML {
+		switch r {
+		case '&':
+			return "&amp;"
+		case '<':
+			return "&lt;"
+		case '>':
+			return "&gt;"
+		}
+		return string(r)
+	}
+
+	special := markdownV2Special
+	if code {
+		special = "`\\"
+	}
+	if r < utf8.RuneSelf && strings.ContainsRune(special, r) {
+		return "\\" + string(r)
+	}
+	return string(r)
+}
+
+// escapeMarkdownURL экранирует адрес ссылки в MarkdownV2.
+func escapeMarkdownURL(url string) string {
+	return strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(url)
+}
@@ -0,0 +1,95 @@
+package telegram
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRenderMarkup(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		entities  []tgbotapi.MessageEntity
+		parseMode string
+		want      string
+	}{
+		{
+			name:      "html escapes special characters",
+			text:      "a < b & c > d",
+			parseMode: tgbotapi.ModeHTML,
+			want:      "a &lt; b &amp; c &gt; d",
+		},
+		{
+			name:      "markdown escapes special characters",
+			text:      "1.5 (x_y) *!",
+			parseMode: tgbotapi.ModeMarkdownV2,
+			want:      `1\.5 \(x\_y\) \*\!`,
+		},
+		{
+			name:      "html nested bold and link",
+			text:      "День рождения",
+			entities:  []tgbotapi.MessageEntity{{Type: "bold", Offset: 0, Length: 13}, {Type: "text_link", Offset: 5, Length: 8, URL: "https://example.com/?a=1&b=2"}},
+			parseMode: tgbotapi.ModeHTML,
+			want:      `<b>День <a href="https://example.com/?a=1&amp;b=2">рождения</a></b>`,
+		},
+		{
+			name:      "markdown link url escapes parenthesis",
+			text:      "ссылка",
+			entities:  []tgbotapi.MessageEntity{{Type: "text_link", Offset: 0, Length: 6, URL: "https://example.com/a_(b)"}},
+			parseMode: tgbotapi.ModeMarkdownV2,
+			want:      `[ссылка](https://example.com/a_(b\))`,
+		},
+		{
+			name:      "markdown code escapes only backtick and backslash",
+			text:      "a.b `c`",
+			entities:  []tgbotapi.MessageEntity{{Type: "code", Offset: 0, Length: 7}},
+			parseMode: tgbotapi.ModeMarkdownV2,
+			want:      "`a.b \\`c\\``",
+		},
+		{
+			name:      "crossing entity is dropped",
+			text:      "abcdef",
+			entities:  []tgbotapi.MessageEntity{{Type: "bold", Offset: 0, Length: 4}, {Type: "italic", Offset: 2, Length: 4}},
+			parseMode: tgbotapi.ModeHTML,
+			want:      "<b>abcd</b>ef",
+		},
+		{
+			name:      "markdown underline and italic on the same range",
+			text:      "текст",
+			entities:  []tgbotapi.MessageEntity{{Type: "underline", Offset: 0, Length: 5}, {Type: "italic", Offset: 0, Length: 5}},
+			parseMode: tgbotapi.ModeMarkdownV2,
+			want:      "__\r_текст_\r__",
+		},
+		{
+			name:      "markdown adjacent italic entities",
+			text:      "ab",
+			entities:  []tgbotapi.MessageEntity{{Type: "italic", Offset: 0, Length: 1}, {Type: "italic", Offset: 1, Length: 1}},
+			parseMode: tgbotapi.ModeMarkdownV2,
+			want:      "_a_\r_b_",
+		},
+		{
+			name:      "emoji counts as two utf-16 units",
+			text:      "🎉 праздник",
+			entities:  []tgbotapi.MessageEntity{{Type: "bold", Offset: 3, Length: 8}},
+			parseMode: tgbotapi.ModeHTML,
+			want:      "🎉 <b>праздник</b>",
+		},
+		{
+			name:      "mention is left to telegram",
+			text:      "@user",
+			entities:  []tgbotapi.MessageEntity{{Type: "mention", Offset: 0, Length: 5}},
+			parseMode: tgbotapi.ModeMarkdownV2,
+			want:      "@user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderMarkup(tt.text, tt.entities, tt.parseMode)
+			if got != tt.want {
+				t.Errorf("renderMarkup() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+)
+
+const outboxBatchSize = 50
+
+// DrainOutbox отправляет сообщения из очереди, время попытки которых
+// наступило. Неудачные попытки откладываются с экспоненциальной задержкой.
+func (f *Forwarder) DrainOutbox(ctx context.Context) error {
+	f.outboxMu.Lock()
+	defer f.outboxMu.Unlock()
+
+	processed := make(map[int64]bool)
+	for {
+		messages, err := f.repository.GetDueOutboxMessages(ctx, outboxBatchSize)
+		if err != nil {
+			return err
+		}
+
+		progress := false
+		for _, msg := range messages {
+			if processed[msg.ID] {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			processed[msg.ID] = true
+			progress = true
+			f.deliverOutboxMessage(ctx, msg)
+		}
+
+		if !progress {
+			return nil
+		}
+	}
+}
+
+// RunOutboxWorker периодически разбирает очередь, пока не отменен ctx.
+func (f *Forwarder) RunOutboxWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.DrainOutbox(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Ошибка при разборе очереди отправки: %v", err)
+			}
+		}
+	}
+}
+
+func (f *Forwarder) deliverOutboxMessage(ctx context.Context, msg *database.OutboxMessage) {
+	sent, err := f.sendMessage(ctx, f.outboxMessageConfig(msg))
+	if err != nil && isEntityParseError(err) {
+		// Одно сообщение с неразобранной разметкой не должно застревать
+		// в очереди: отправляем его как обычный текст.
+		log.Printf("Telegram не разобрал разметку сообщения %d, отправляем без форматирования: %v", msg.ID, err)
+		sent, err = f.sendMessage(ctx, tgbotapi.NewMessage(msg.ChatID, msg.MessageText))
+	}
+	if err != nil {
+		f.handleSendFailure(ctx, msg, err)
+	} else {
+		log.Printf("Напоминание отправлено пользователю %d", msg.ChatID)
+		if err := f.repository.MarkOutboxSent(ctx, msg.ID, sent.MessageID); err != nil {
+			log.Printf("Ошибка при сохранении отправки сообщения %d: %v", msg.ID, err)
+		}
+	}
+
+	if err := f.repository.RefreshDeliveryStatus(ctx, msg.ChatID); err != nil {
+		log.Printf("Ошибка при обновлении статуса получателя %d: %v", msg.ChatID, err)
+	}
+}
+
+// handleSendFailure откладывает сообщение при временной ошибке. При постоянной
+// (пользователь заблокировал бота, удалил аккаунт и т.п.) сообщение сразу
+// переводится в dead, получатель отключается, а остальные сообщения ему
+// отменяются.
+func (f *Forwarder) handleSendFailure(ctx context.Context, msg *database.OutboxMessage, err error) {
+	kind := classifySendError(err)
+	nextAttemptAt := f.clock.Now().Add(f.retryBackoff(msg.Attempts + 1))
+	dead := isLastAttempt(msg, kind)
+
+	markErr := f.repository.MarkOutboxFailed(ctx, msg.ID, err.Error(), nextAttemptAt, dead)
+	switch {
+	case markErr != nil:
+		log.Printf("Ошибка при сохранении неудачной попытки отправки %d: %v", msg.ID, markErr)
+	case kind.isPermanent():
+		log.Printf("Сообщение %d пользователю %d не может быть доставлено (%s): %v", msg.ID, msg.ChatID, kind, err)
+	case dead:
+		log.Printf("Сообщение %d пользователю %d не отправлено после %d попыток: %v", msg.ID, msg.ChatID, msg.Attempts+1, err)
+	default:
+		log.Printf("Ошибка при отправке пользователю %d (попытка %d), следующая попытка в %s: %v",
+			msg.ChatID, msg.Attempts+1, nextAttemptAt.Format("15:04:05"), err)
+	}
+
+	if !kind.isPermanent() {
+		return
+	}
+
+	if err := f.repository.DeactivateRecipient(ctx, msg.ChatID, string(kind)); err != nil {
+		log.Printf("Ошибка при отключении получателя %d: %v", msg.ChatID, err)
+		return
+	}
+	log.Printf("Получатель %d отключен, причина: %s", msg.ChatID, kind)
+
+	cancelled, err := f.repository.CancelPendingMessages(ctx, msg.ChatID, string(kind))
+	if err != nil {
+		log.Printf("Ошибка при отмене сообщений получателю %d: %v", msg.ChatID, err)
+	} else if cancelled > 0 {
+		log.Printf("Отменено сообщений в очереди для получателя %d: %d", msg.ChatID, cancelled)
+	}
+}
+
+// isLastAttempt после неудачной попытки сообщение больше не отправляется:
+// ошибка постоянная или попытки исчерпаны.
+func isLastAttempt(msg *database.OutboxMessage, kind sendErrorKind) bool {
+	return kind.isPermanent() || msg.Attempts+1 >= msg.MaxAttempts
+}
+
+// retryBackoff задержка перед попыткой attempt+1: retryBaseBackoff * 2^(attempt-1),
+// но не больше retryMaxBackoff.
+func (f *Forwarder) retryBackoff(attempt int) time.Duration {
+	backoff := f.retryBaseBackoff
+	for i := 1; i < attempt && backoff < f.retryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > f.retryMaxBackoff {
+		backoff = f.retryMaxBackoff
+	}
+	return backoff
+}
+
+// outboxMessageConfig готовит сообщение из очереди к отправке: в режиме
+// HTML или MarkdownV2 текст с entities переводится в разметку.
+func (f *Forwarder) outboxMessageConfig(msg *database.OutboxMessage) tgbotapi.MessageConfig {
+	entities, err := decodeEntities(msg.MessageEntities)
+	if err != nil {
+		log.Printf("Сообщение %d будет отправлено без форматирования: %v", msg.ID, err)
+	}
+
+	if f.parseMode == "" {
+		config := tgbotapi.NewMessage(msg.ChatID, msg.MessageText)
+		config.Entities = entities
+		return config
+	}
+
+	config := tgbotapi.NewMessage(msg.ChatID, renderMarkup(msg.MessageText, entities, f.parseMode))
+	config.ParseMode = f.parseMode
+	return config
+}
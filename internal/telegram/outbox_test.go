@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	f := &Forwarder{retryBaseBackoff: time.Second, retryMaxBackoff: 10 * time.Second}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: 2 * time.Second},
+		{attempt: 3, want: 4 * time.Second},
+		{attempt: 4, want: 8 * time.Second},
+		{attempt: 5, want: 10 * time.Second},
+		{attempt: 10, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := f.retryBackoff(tt.attempt); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, ожидалось %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestIsLastAttempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		kind     sendErrorKind
+		want     bool
+	}{
+		{name: "transient error below max_attempts", attempts: 3, kind: sendErrorTransient, want: false},
+		{name: "transient error reaches max_attempts", attempts: 4, kind: sendErrorTransient, want: true},
+		{name: "permanent error on first attempt", attempts: 0, kind: sendErrorBlocked, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &database.OutboxMessage{Attempts: tt.attempts, MaxAttempts: 5}
+			if got := isLastAttempt(msg, tt.kind); got != tt.want {
+				t.Errorf("isLastAttempt() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+// eventListDiff изменения списка событий между двумя версиями закрепленного сообщения.
+type eventListDiff struct {
+	added   []*parser.EventEntry
+	removed []*parser.EventEntry
+}
+
+func (d eventListDiff) isEmpty() bool {
+	return len(d.added) == 0 && len(d.removed) == 0
+}
+
+// handlePinnedMessage обрабатывает служебное сообщение о закреплении
+// нового сообщения в группе.
+func (f *Forwarder) handlePinnedMessage(ctx context.Context, msg *tgbotapi.Message) {
+	if _, ok := f.source(msg.Chat.ID); !ok {
+		return
+	}
+
+	pinned := msg.PinnedMessage
+	log.Printf("В группе %d закреплено сообщение %d", msg.Chat.ID, pinned.MessageID)
+	text, entities := messageContent(pinned)
+	encoded, err := encodeEntities(entities)
+	if err != nil {
+		log.Printf("Ошибка при сохранении закрепленного сообщения: %v", err)
+		return
+	}
+	if err := f.repository.SavePinnedMessage(ctx, msg.Chat.ID, pinned.MessageID, text, encoded); err != nil {
+		log.Printf("Ошибка при сохранении закрепленного сообщения: %v", err)
+		return
+	}
+	f.onPinnedMessagesChanged(ctx, msg.Chat.ID)
+}
+
+// handleEditedMessage обрабатывает правку сообщения в группе. Интересны
+// только правки закрепленных сообщений.
+func (f *Forwarder) handleEditedMessage(ctx context.Context, msg *tgbotapi.Message) {
+	if _, ok := f.source(msg.Chat.ID); !ok {
+		return
+	}
+
+	text, entities := messageContent(msg)
+	encoded, err := encodeEntities(entities)
+	if err != nil {
+		log.Printf("Ошибка при обновлении закрепленного сообщения: %v", err)
+		return
+	}
+
+	pinned, err := f.repository.UpdatePinnedMessageText(ctx, msg.Chat.ID, msg.MessageID, text, encoded)
+	if err != nil {
+		log.Printf("Ошибка при обновлении закрепленного сообщения: %v", err)
+		return
+	}
+	if !pinned {
+		return
+	}
+
+	log.Printf("Закрепленное сообщение %d в группе %d отредактировано", msg.MessageID, msg.Chat.ID)
+	f.onPinnedMessagesChanged(ctx, msg.Chat.ID)
+}
+
+// onEventListChanged сообщает об изменениях и, если включено sendOnChange и в
+// списке появились новые события, сразу запускает рассылку, не дожидаясь
+// расписания. Уже отправленные напоминания повторно не отправляются.
+func (f *Forwarder) onEventListChanged(ctx context.Context, chatID int64, diff eventListDiff) {
+	if diff.isEmpty() {
+		log.Println("Список событий не изменился")
+		return
+	}
+
+	f.reportEventListChanges(ctx, chatID, diff)
+
+	if f.sendOnChange && len(diff.added) > 0 {
+		go func() {
+			if err := f.ForwardPinnedMessage(ctx, chatID); err != nil {
+				log.Printf("Ошибка при отправке напоминаний после изменения списка: %v", err)
+			}
+		}()
+	}
+}
+
+// syncPinnedSnapshot сравнивает список событий из закрепленных сообщений
+// с сохраненной версией и сохраняет новую. При первом сохранении изменений нет.
+func (f *Forwarder) syncPinnedSnapshot(ctx context.Context, chatID int64, messages []*database.PinnedMessage) (eventListDiff, error) {
+	previous, err := f.repository.GetPinnedSnapshot(ctx, chatID)
+	if err != nil && !errors.Is(err, database.ErrPinnedSnapshotNotFound) {
+		return eventListDiff{}, err
+	}
+
+	text := mergedPinnedText(messages)
+	var diff eventListDiff
+	if previous != nil {
+		if previous.MessageText == text {
+			return diff, nil
+		}
+		diff = f.diffEventLists(previous.MessageText, text)
+	}
+
+	if err := f.repository.SavePinnedSnapshot(ctx, chatID, latestPinnedID(messages), text); err != nil {
+		return eventListDiff{}, err
+	}
+	return diff, nil
+}
+
+// diffEventLists сравнивает списки событий по дате и описанию.
+func (f *Forwarder) diffEventLists(oldText, newText string) eventListDiff {
+	oldEvents := validEventsByHash(f.parser.ParseEventList(oldText))
+	newEvents := validEventsByHash(f.parser.ParseEventList(newText))
+
+	var diff eventListDiff
+	for _, e := range newEvents {
+		if !containsHash(oldEvents, e.hash) {
+			diff.added = append(diff.added, e.event)
+		}
+	}
+	for _, e := range oldEvents {
+		if !containsHash(newEvents, e.hash) {
+			diff.removed = append(diff.removed, e.event)
+		}
+	}
+	return diff
+}
+
+type hashedEvent struct {
+	hash  string
+	event *parser.EventEntry
+}
+
+// validEventsByHash оставляет распознанные события в исходном порядке.
+func validEventsByHash(events []*parser.EventEntry) []hashedEvent {
+	var hashed []hashedEvent
+	for _, event := range events {
+		if event.IsValid {
+			hashed = append(hashed, hashedEvent{
+				hash:  database.GenerateEventHash(event.Date, event.Description),
+				event: event,
+			})
+		}
+	}
+	return hashed
+}
+
+func containsHash(events []hashedEvent, hash string) bool {
+	for _, e := range events {
+		if e.hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// reportEventListChanges пишет изменения в лог и, если включено, отправляет
+// сводку администраторам группы chatID.
+func (f *Forwarder) reportEventListChanges(ctx context.Context, chatID int64, diff eventListDiff) {
+	log.Printf("Список событий группы %d обновлен: добавлено %d, удалено %d", chatID, len(diff.added), len(diff.removed))
+	if !f.notifyAdminsOnChange {
+		return
+	}
+
+	source, _ := f.source(chatID)
+	summary := f.formatEventListDiff(source, diff)
+	admins, err := f.bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatID},
+	})
+	if err != nil {
+		log.Printf("Не удалось получить список администраторов группы: %v", err)
+		return
+	}
+
+	for _, admin := range admins {
+		if admin.User == nil || admin.User.IsBot {
+			continue
+		}
+		// Администратор, который ни разу не писал боту, сообщение не получит.
+		f.reply(ctx, admin.User.ID, summary)
+	}
+}
+
+// formatEventListDiff сводка изменений на языке по умолчанию: язык
+// администраторов группы боту неизвестен.
+func (f *Forwarder) formatEventListDiff(source Source, diff eventListDiff) string {
+	templates := f.templates.forLocale(f.locale)
+	title := source.title(f.locale)
+
+	var b strings.Builder
+	b.WriteString(f.locale.T("changes.header", title, len(diff.added), len(diff.removed)))
+	b.WriteString("\n")
+	for _, event := range diff.added {
+		line, _ := templates.renderLine(f.parser, event, title)
+		b.WriteString("\n➕ ")
+		b.WriteString(line)
+	}
+	for _, event := range diff.removed {
+		line, _ := templates.renderLine(f.parser, event, title)
+		b.WriteString("\n➖ ")
+		b.WriteString(line)
+	}
+	return b.String()
+}
@@ -0,0 +1,206 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+// loadPinnedMessages возвращает закрепленные сообщения группы со списками
+// событий. getChat сообщает только о последнем закрепленном сообщении, поэтому
+// оно добавляется к сохраненным из обновлений pinned_message. Если в группе
+// больше нет закрепленных сообщений, сохраненные списки удаляются.
+func (f *Forwarder) loadPinnedMessages(ctx context.Context, chatID int64) ([]*database.PinnedMessage, error) {
+	messages, err := f.repository.GetPinnedMessages(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, latestErr := f.GetPinnedMessage(chatID)
+	switch {
+	case latestErr == nil:
+		text, entities := messageContent(latest)
+		encoded, err := encodeEntities(entities)
+		if err != nil {
+			return nil, err
+		}
+		if isLatestPinned(messages, latest.MessageID, text, encoded) {
+			break
+		}
+		if err := f.repository.SavePinnedMessage(ctx, chatID, latest.MessageID, text, encoded); err != nil {
+			return nil, err
+		}
+		if messages, err = f.repository.GetPinnedMessages(ctx, chatID); err != nil {
+			return nil, err
+		}
+	case errors.Is(latestErr, errNoPinnedMessage):
+		deleted, err := f.repository.DeletePinnedMessages(ctx, chatID)
+		if err != nil {
+			return nil, err
+		}
+		if deleted > 0 {
+			log.Printf("В группе %d не осталось закрепленных сообщений, удалено сохраненных: %d", chatID, deleted)
+		}
+		return nil, latestErr
+	default:
+		log.Printf("Не удалось получить последнее закрепленное сообщение группы %d: %v", chatID, latestErr)
+	}
+
+	messages = f.filterPinnedMessages(messages)
+	if len(messages) == 0 {
+		if latestErr != nil {
+			return nil, latestErr
+		}
+		if f.pinHashtag != "" {
+			return nil, fmt.Errorf("в группе %d нет закрепленных сообщений с %s", chatID, f.pinHashtag)
+		}
+		return nil, fmt.Errorf("закрепленное сообщение не содержит ни текста, ни подписи")
+	}
+
+	return messages, nil
+}
+
+// isLatestPinned проверяет, что сообщение уже сохранено последним закрепленным
+// с тем же текстом и форматированием и его не нужно записывать заново.
+// PostgreSQL хранит JSONB в своем виде, поэтому сохраненное форматирование
+// сравнивается после повторной сериализации.
+func isLatestPinned(messages []*database.PinnedMessage, messageID int, text string, entities []byte) bool {
+	if len(messages) == 0 {
+		return false
+	}
+	last := messages[len(messages)-1]
+	if last.MessageID != messageID || last.MessageText != text {
+		return false
+	}
+	stored, err := decodeEntities(last.MessageEntities)
+	if err != nil {
+		return false
+	}
+	normalized, err := encodeEntities(stored)
+	return err == nil && bytes.Equal(normalized, entities)
+}
+
+// filterPinnedMessages оставляет сообщения с текстом или подписью и, если
+// задан хэштег, только помеченные им.
+func (f *Forwarder) filterPinnedMessages(messages []*database.PinnedMessage) []*database.PinnedMessage {
+	var filtered []*database.PinnedMessage
+	for _, msg := range messages {
+		if msg.MessageText == "" {
+			continue
+		}
+		if f.pinHashtag != "" && !strings.Contains(strings.ToLower(msg.MessageText), strings.ToLower(f.pinHashtag)) {
+			continue
+		}
+		filtered = append(filtered, msg)
+	}
+	return filtered
+}
+
+// mergedPinnedText объединяет тексты закрепленных сообщений в один список.
+func mergedPinnedText(messages []*database.PinnedMessage) string {
+	texts := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		texts = append(texts, msg.MessageText)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// latestPinnedID ID последнего закрепленного сообщения или 0.
+func latestPinnedID(messages []*database.PinnedMessage) int {
+	if len(messages) == 0 {
+		return 0
+	}
+	return messages[len(messages)-1].MessageID
+}
+
+// parsePinnedMessages разбирает каждое закрепленное сообщение вместе с его
+// форматированием и запоминает, из какого сообщения взято событие. Событие,
+// которое есть в нескольких сообщениях (например, список закрепили заново,
+// а старое закрепление осталось), берется из последнего из них.
+func (f *Forwarder) parsePinnedMessages(messages []*database.PinnedMessage) ([]*parser.EventEntry, map[*parser.EventEntry]int) {
+	var events []*parser.EventEntry
+	sourceIDs := make(map[*parser.EventEntry]int)
+	indexByHash := make(map[string]int)
+	for _, msg := range messages {
+		entities, err := decodeEntities(msg.MessageEntities)
+		if err != nil {
+			log.Printf("Форматирование закрепленного сообщения %d не будет перенесено: %v", msg.MessageID, err)
+		}
+		for _, event := range f.parser.ParseEventListWithEntities(msg.MessageText, toParserEntities(entities)) {
+			if event.IsValid {
+				hash := database.GenerateEventHash(event.Date, event.Description)
+				if i, ok := indexByHash[hash]; ok {
+					delete(sourceIDs, events[i])
+					events[i] = event
+					sourceIDs[event] = msg.MessageID
+					continue
+				}
+				indexByHash[hash] = len(events)
+			}
+			events = append(events, event)
+			sourceIDs[event] = msg.MessageID
+		}
+	}
+	return events, sourceIDs
+}
+
+// onPinnedMessagesChanged пересобирает список событий группы из сохраненных
+// закрепленных сообщений и сообщает об изменениях.
+func (f *Forwarder) onPinnedMessagesChanged(ctx context.Context, chatID int64) {
+	messages, err := f.repository.GetPinnedMessages(ctx, chatID)
+	if err != nil {
+		log.Printf("Ошибка при получении закрепленных сообщений: %v", err)
+		return
+	}
+
+	diff, err := f.syncPinnedSnapshot(ctx, chatID, f.filterPinnedMessages(messages))
+	if err != nil {
+		log.Printf("Ошибка при обновлении снимка закрепленного сообщения: %v", err)
+		return
+	}
+	f.onEventListChanged(ctx, chatID, diff)
+}
+
+// handleForgetPin убирает сообщение из списка закрепленных. Telegram не
+// присылает боту событие об откреплении, поэтому открепленные списки нужно
+// убирать вручную: ответом на сообщение или по его ID.
+func (f *Forwarder) handleForgetPin(ctx context.Context, msg *tgbotapi.Message, loc *locale.Locale) (string, error) {
+	if msg.Chat.IsPrivate() {
+		return "", commandError(loc.T("forgetpin.group_only"))
+	}
+
+	var messageID int
+	switch arg := strings.TrimSpace(msg.CommandArguments()); {
+	case arg != "":
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return "", commandError(loc.T("forgetpin.usage"))
+		}
+		messageID = id
+	case msg.ReplyToMessage != nil:
+		messageID = msg.ReplyToMessage.MessageID
+	default:
+		return "", commandError(loc.T("forgetpin.usage"))
+	}
+
+	deleted, err := f.repository.DeletePinnedMessage(ctx, msg.Chat.ID, messageID)
+	if err != nil {
+		return "", err
+	}
+	if !deleted {
+		return "", commandError(loc.T("forgetpin.not_found", messageID))
+	}
+
+	log.Printf("Администратор %d убрал сообщение %d группы %d из закрепленных", msg.From.ID, messageID, msg.Chat.ID)
+	f.onPinnedMessagesChanged(ctx, msg.Chat.ID)
+	return loc.T("forgetpin.done", messageID), nil
+}
@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+func TestParsePinnedMessagesDeduplicates(t *testing.T) {
+	f := &Forwarder{parser: parser.NewParser(parser.FixedClock{Time: time.Date(2026, time.March, 10, 9, 0, 0, 0, time.UTC)})}
+
+	tests := []struct {
+		name      string
+		messages  []*database.PinnedMessage
+		want      []string
+		wantPinID []int
+	}{
+		{
+			name: "different events from two pins",
+			messages: []*database.PinnedMessage{
+				{MessageID: 1, MessageText: "15 марта Встреча"},
+				{MessageID: 2, MessageText: "20 марта Отчет"},
+			},
+			want:      []string{"Встреча", "Отчет"},
+			wantPinID: []int{1, 2},
+		},
+		{
+			name: "re-posted list keeps the latest pin",
+			messages: []*database.PinnedMessage{
+				{MessageID: 1, MessageText: "15 марта Встреча\n20 марта Отчет"},
+				{MessageID: 2, MessageText: "15 марта Встреча\n25 марта Праздник"},
+			},
+			want:      []string{"Встреча", "Отчет", "Праздник"},
+			wantPinID: []int{2, 1, 2},
+		},
+		{
+			name: "same line twice in one pin",
+			messages: []*database.PinnedMessage{
+				{MessageID: 1, MessageText: "15 марта Встреча\n15 марта Встреча"},
+			},
+			want:      []string{"Встреча"},
+			wantPinID: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, sourceIDs := f.parsePinnedMessages(tt.messages)
+
+			var got []string
+			var pinIDs []int
+			for _, event := range events {
+				got = append(got, event.Description)
+				pinIDs = append(pinIDs, sourceIDs[event])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("события = %q, ожидалось %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(pinIDs, tt.wantPinID) {
+				t.Errorf("закрепленные сообщения = %v, ожидалось %v", pinIDs, tt.wantPinID)
+			}
+			if len(sourceIDs) != len(events) {
+				t.Errorf("в sourceIDs %d записей, событий %d", len(sourceIDs), len(events))
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+// Лимиты Telegram Bot API по умолчанию.
+const (
+	DefaultMessagesPerSecond = 30
+	DefaultPerChatInterval   = time.Second
+)
+
+// rateLimiter распределяет отправки во времени так, чтобы не превышать
+// общий лимит сообщений в секунду и лимит на один чат. После ответа 429
+// все отправки приостанавливаются на retry_after.
+type rateLimiter struct {
+	mu             sync.Mutex
+	clock          parser.Clock
+	globalInterval time.Duration
+	chatInterval   time.Duration
+	nextGlobal     time.Time
+	nextByChat     map[int64]time.Time
+	pausedUntil    time.Time
+}
+
+func newRateLimiter(messagesPerSecond int, chatInterval time.Duration, clock parser.Clock) *rateLimiter {
+	if messagesPerSecond < 1 {
+		messagesPerSecond = DefaultMessagesPerSecond
+	}
+	if chatInterval <= 0 {
+		chatInterval = DefaultPerChatInterval
+	}
+
+	return &rateLimiter{
+		clock:          clock,
+		globalInterval: time.Second / time.Duration(messagesPerSecond),
+		chatInterval:   chatInterval,
+		nextByChat:     make(map[int64]time.Time),
+	}
+}
+
+// Wait резервирует ближайший разрешенный момент отправки в chatID и ждет его.
+func (l *rateLimiter) Wait(ctx context.Context, chatID int64) error {
+	delay := l.reserve(chatID)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve резервирует ближайший разрешенный момент отправки в chatID и
+// возвращает, сколько до него осталось.
+func (l *rateLimiter) reserve(chatID int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	at := latest(now, l.nextGlobal, l.nextByChat[chatID], l.pausedUntil)
+	l.nextGlobal = at.Add(l.globalInterval)
+	l.nextByChat[chatID] = at.Add(l.chatInterval)
+	l.forgetIdleChats(now)
+
+	return at.Sub(now)
+}
+
+// Pause приостанавливает все отправки на d.
+func (l *rateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := l.clock.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// forgetIdleChats удаляет чаты, лимит которых уже не действует,
+// чтобы карта не росла бесконечно.
+func (l *rateLimiter) forgetIdleChats(now time.Time) {
+	if len(l.nextByChat) < 1000 {
+		return
+	}
+	for chatID, next := range l.nextByChat {
+		if next.Before(now) {
+			delete(l.nextByChat, chatID)
+		}
+	}
+}
+
+func latest(times ...time.Time) time.Time {
+	var result time.Time
+	for _, t := range times {
+		if t.After(result) {
+			result = t
+		}
+	}
+	return result
+}
+
+// retryAfter возвращает время ожидания из ответа 429 Too Many Requests.
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter <= 0 {
+		return 0, false
+	}
+	return time.Duration(apiErr.RetryAfter) * time.Second, true
+}
@@ -0,0 +1,108 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	clock := parser.FixedClock{Time: time.Date(2026, time.March, 10, 9, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name    string
+		pause   time.Duration
+		chatIDs []int64
+		want    []time.Duration
+	}{
+		{
+			name:    "global rate spaces different chats",
+			chatIDs: []int64{1, 2, 3},
+			want:    []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:    "per-chat interval",
+			chatIDs: []int64{1, 2, 1},
+			want:    []time.Duration{0, 100 * time.Millisecond, time.Second},
+		},
+		{
+			name:    "retry_after pause delays every chat",
+			pause:   5 * time.Second,
+			chatIDs: []int64{1, 2, 1},
+			want:    []time.Duration{5 * time.Second, 5*time.Second + 100*time.Millisecond, 6 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(10, time.Second, clock)
+			if tt.pause > 0 {
+				l.Pause(tt.pause)
+			}
+
+			var got []time.Duration
+			for _, chatID := range tt.chatIDs {
+				got = append(got, l.reserve(chatID))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("задержки = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	clock := parser.FixedClock{Time: time.Date(2026, time.March, 10, 9, 0, 0, 0, time.UTC)}
+	l := newRateLimiter(10, time.Second, clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := l.Wait(ctx, 1); err != nil {
+		t.Fatalf("первая отправка не должна ждать: %v", err)
+	}
+	if err := l.Wait(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() = %v, ожидалась отмена контекста", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		want    time.Duration
+		limited bool
+	}{
+		{
+			name:    "too many requests",
+			err:     &tgbotapi.Error{Code: 429, Message: "Too Many Requests: retry after 7", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 7}},
+			want:    7 * time.Second,
+			limited: true,
+		},
+		{
+			name: "api error without retry_after",
+			err:  &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"},
+		},
+		{
+			name: "network error",
+			err:  errors.New("connection reset"),
+		},
+		{
+			name: "no error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, limited := retryAfter(tt.err)
+			if got != tt.want || limited != tt.limited {
+				t.Errorf("retryAfter() = %v, %v, ожидалось %v, %v", got, limited, tt.want, tt.limited)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+// Этапы напоминаний, по которым ведется учет в sent_events и event_deliveries.
+const (
+	// stageUpcoming обычное напоминание за days_ahead дней.
+	stageUpcoming = ""
+	// stageSameDay напоминание в день события за несколько часов до начала.
+	stageSameDay = "same_day"
+)
+
+// leadTimeStage этап напоминания за days дней до события: по отметке из
+// закрепленного сообщения или по app.reminder_stages.
+func leadTimeStage(days int) string {
+	return fmt.Sprintf("lead_%dd", days)
+}
+
+// dueStage этап напоминания о событии, который наступил при текущем запуске.
+type dueStage struct {
+	event *parser.EventEntry
+	hash  string
+	stage string
+}
+
+// deliveryPlan этапы, которые нужно доставить одному получателю.
+type deliveryPlan struct {
+	recipient *database.Recipient
+	stages    []dueStage
+}
+
+// collectDueStages отбирает этапы напоминаний, которые наступили сейчас,
+// независимо от того, отправлялись ли они раньше.
+func (f *Forwarder) collectDueStages(source Source, events []*parser.EventEntry) []dueStage {
+	var due []dueStage
+	add := func(event *parser.EventEntry, stage string) {
+		due = append(due, dueStage{
+			event: event,
+			hash:  database.GenerateEventHash(event.Date, event.Description),
+			stage: stage,
+		})
+	}
+
+	var defaultEvents []*parser.EventEntry
+	for _, event := range events {
+		if len(event.LeadTimes) == 0 && len(f.reminderStages) == 0 {
+			defaultEvents = append(defaultEvents, event)
+			continue
+		}
+		if days, ok := f.parser.DueLeadTime(event, f.reminderStages); ok {
+			log.Printf("Этап напоминания \"за %d дн.\": %s", days, event.RawDate)
+			add(event, leadTimeStage(days))
+		}
+	}
+
+	upcomingEvents := f.parser.GetUpcomingEvents(defaultEvents, source.DaysAhead)
+	log.Printf("Найдено предстоящих событий: %d", len(upcomingEvents))
+	for _, event := range upcomingEvents {
+		add(event, stageUpcoming)
+	}
+
+	if f.sameDayHoursBefore > 0 {
+		lead := time.Duration(f.sameDayHoursBefore) * time.Hour
+		startingEvents := f.parser.GetEventsStartingWithin(events, lead)
+		log.Printf("Событий, начинающихся в ближайшие %d ч.: %d", f.sameDayHoursBefore, len(startingEvents))
+		for _, event := range startingEvents {
+			add(event, stageSameDay)
+		}
+	}
+
+	return due
+}
+
+// planDeliveries решает, кому какие этапы отправить. Новый этап отмечается
+// в sent_events и назначается всем активным получателям; по уже отправленному
+// этапу повторно получают напоминание только те, кому оно не было доставлено.
+func (f *Forwarder) planDeliveries(ctx context.Context, sourceChatID int64, due []dueStage, recipients []*database.Recipient) []*deliveryPlan {
+	userIDs := make([]int64, 0, len(recipients))
+	byUserID := make(map[int64]*database.Recipient, len(recipients))
+	for _, recipient := range recipients {
+		userIDs = append(userIDs, recipient.UserID)
+		byUserID[recipient.UserID] = recipient
+	}
+
+	var plans []*deliveryPlan
+	planByUserID := make(map[int64]*deliveryPlan)
+	assign := func(userID int64, d dueStage) {
+		recipient, ok := byUserID[userID]
+		if !ok {
+			return
+		}
+		plan, ok := planByUserID[userID]
+		if !ok {
+			plan = &deliveryPlan{recipient: recipient}
+			planByUserID[userID] = plan
+			plans = append(plans, plan)
+		}
+		plan.stages = append(plan.stages, d)
+	}
+
+	for _, d := range due {
+		event := d.event
+		isSent, err := f.repository.IsEventSent(ctx, sourceChatID, d.hash, d.stage)
+		if err != nil {
+			log.Printf("Ошибка при проверке отправленного события: %v", err)
+			continue
+		}
+
+		targets := userIDs
+		if isSent {
+			targets, err = f.repository.GetUndeliveredUserIDs(ctx, sourceChatID, d.hash, d.stage)
+			if err != nil {
+				log.Printf("Ошибка при получении недоставленных напоминаний: %v", err)
+				continue
+			}
+			if len(targets) == 0 {
+				log.Printf("Событие уже было отправлено: %s - %s", event.Date.Format("2006-01-02"), event.Description)
+				continue
+			}
+			log.Printf("Повторная отправка %d получателям: %s - %s", len(targets), event.Date.Format("2006-01-02"), event.Description)
+		} else {
+			if err := f.repository.MarkEventAsSent(ctx, sourceChatID, event.Date, event.Description, d.hash, d.stage, userIDs); err != nil {
+				log.Printf("Ошибка при сохранении информации об отправленном событии: %v", err)
+				continue
+			}
+			log.Printf("Событие помечено как отправленное: %s - %s", event.Date.Format("2006-01-02"), event.Description)
+		}
+
+		for _, userID := range targets {
+			assign(userID, d)
+		}
+	}
+
+	return plans
+}
+
+// reminderPart часть напоминания и этапы событий, которые в нее вошли.
+type reminderPart struct {
+	text     string
+	entities []parser.Entity
+	stages   []dueStage
+}
+
+// buildReminderParts собирает напоминание на языке loc по шаблонам с форматированием из
+// закрепленного сообщения: ссылками, упоминаниями и выделением. Если событие
+// попало в несколько этапов сразу, оно упоминается один раз. Напоминание
+// длиннее лимита Telegram делится на части по границам событий, подвал
+// выводится в последней части.
+func (f *Forwarder) buildReminderParts(source Source, stages []dueStage, loc *locale.Locale) []reminderPart {
+	templates := f.templates.forLocale(loc)
+	eventParser := f.parser.WithLocale(loc)
+	title := source.title(loc)
+
+	var blocks []textBlock
+	var blockStages [][]dueStage
+	blockIndex := make(map[*parser.EventEntry]int)
+	for _, d := range stages {
+		if i, ok := blockIndex[d.event]; ok {
+			blockStages[i] = append(blockStages[i], d)
+			continue
+		}
+
+		line, entities := templates.renderLine(eventParser, d.event, title)
+		if line == "" {
+			continue
+		}
+		blockIndex[d.event] = len(blocks)
+		blocks = append(blocks, textBlock{text: line, entities: entities})
+		blockStages = append(blockStages, []dueStage{d})
+	}
+
+	data := MessageData{Source: title, Count: len(blocks)}
+	if footer := templates.renderFooter(data); footer != "" {
+		blocks = append(blocks, textBlock{text: "\n" + footer})
+		blockStages = append(blockStages, nil)
+	}
+
+	header := func(part, total int) string {
+		data.Part, data.Parts = part, total
+		return templates.renderHeader(data) + "\n"
+	}
+
+	chunks := splitMessage(header, blocks, maxMessageLength)
+	parts := make([]reminderPart, 0, len(chunks))
+	for _, chunk := range chunks {
+		part := reminderPart{text: chunk.text, entities: chunk.entities}
+		for _, i := range chunk.blocks {
+			part.stages = append(part.stages, blockStages[i]...)
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
@@ -0,0 +1,146 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/database"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+)
+
+// Source группа с закрепленным списком событий.
+type Source struct {
+	ChatID int64
+	// Name название группы для сообщений бота; если пусто, выводится ID.
+	Name string
+	// DaysAhead за сколько дней напоминать о событиях этой группы.
+	DaysAhead int
+	// UserIDs получатели напоминаний из этой группы. Если пусто, напоминания
+	// получают активные получатели, которые состоят в группе.
+	UserIDs []int64
+}
+
+func (s Source) title(loc *locale.Locale) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return loc.T("source.untitled", s.ChatID)
+}
+
+// source возвращает настройки группы chatID.
+func (f *Forwarder) source(chatID int64) (Source, bool) {
+	for _, s := range f.sources {
+		if s.ChatID == chatID {
+			return s, true
+		}
+	}
+	return Source{}, false
+}
+
+// sourcesForChat группы, к которым относится команда: в личных сообщениях
+// все группы, в группе только она сама.
+func (f *Forwarder) sourcesForChat(chat *tgbotapi.Chat) []Source {
+	if chat.IsPrivate() {
+		return f.sources
+	}
+	if s, ok := f.source(chat.ID); ok {
+		return []Source{s}
+	}
+	return nil
+}
+
+// isChatMember пользователь состоит в группе: создатель, администратор,
+// участник или участник с ограничениями, который не вышел из группы.
+func isChatMember(member tgbotapi.ChatMember) bool {
+	switch member.Status {
+	case "creator", "administrator", "member":
+		return true
+	case "restricted":
+		return member.IsMember
+	}
+	return false
+}
+
+// isSourceMember пользователь userID получает напоминания группы source: он
+// указан в ее user_ids, а если список не задан — состоит в группе.
+func (f *Forwarder) isSourceMember(source Source, userID int64) (bool, error) {
+	if len(source.UserIDs) > 0 {
+		for _, id := range source.UserIDs {
+			if id == userID {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	member, err := f.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
+			ChatID: source.ChatID,
+			UserID: userID,
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("не удалось получить статус пользователя %d в группе %d: %w", userID, source.ChatID, err)
+	}
+	return isChatMember(member), nil
+}
+
+// memberSources оставляет группы из sources, напоминания которых получает
+// пользователь. Ошибка возвращается, только если таких групп нет и статус
+// хотя бы в одной группе получить не удалось.
+func (f *Forwarder) memberSources(userID int64, sources []Source) ([]Source, error) {
+	var allowed []Source
+	var lastErr error
+	for _, source := range sources {
+		ok, err := f.isSourceMember(source, userID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if ok {
+			allowed = append(allowed, source)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, lastErr
+	}
+	return allowed, nil
+}
+
+// recipientsForSource оставляет получателей, которые получают напоминания
+// группы: указанных в ее user_ids или, если список не задан, состоящих в
+// группе. Получатель, статус которого получить не удалось, пропускается.
+func (f *Forwarder) recipientsForSource(source Source, recipients []*database.Recipient) []*database.Recipient {
+	var filtered []*database.Recipient
+	for _, recipient := range recipients {
+		ok, err := f.isSourceMember(source, recipient.UserID)
+		if err != nil {
+			log.Printf("Получатель %d пропущен: %v", recipient.UserID, err)
+			continue
+		}
+		if ok {
+			filtered = append(filtered, recipient)
+		}
+	}
+	return filtered
+}
+
+// recipientsForSources оставляет получателей, подписанных хотя бы на одну
+// из групп sources, в исходном порядке.
+func (f *Forwarder) recipientsForSources(sources []Source, recipients []*database.Recipient) []*database.Recipient {
+	included := make(map[int64]bool)
+	for _, source := range sources {
+		for _, recipient := range f.recipientsForSource(source, recipients) {
+			included[recipient.UserID] = true
+		}
+	}
+
+	var filtered []*database.Recipient
+	for _, recipient := range recipients {
+		if included[recipient.UserID] {
+			filtered = append(filtered, recipient)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,43 @@
+package telegram
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestIsChatMember(t *testing.T) {
+	tests := []struct {
+		member tgbotapi.ChatMember
+		want   bool
+	}{
+		{member: tgbotapi.ChatMember{Status: "creator"}, want: true},
+		{member: tgbotapi.ChatMember{Status: "administrator"}, want: true},
+		{member: tgbotapi.ChatMember{Status: "member"}, want: true},
+		{member: tgbotapi.ChatMember{Status: "restricted", IsMember: true}, want: true},
+		{member: tgbotapi.ChatMember{Status: "restricted"}, want: false},
+		{member: tgbotapi.ChatMember{Status: "left"}, want: false},
+		{member: tgbotapi.ChatMember{Status: "kicked"}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isChatMember(tt.member); got != tt.want {
+			t.Errorf("isChatMember(%s, is_member=%v) = %v, ожидалось %v", tt.member.Status, tt.member.IsMember, got, tt.want)
+		}
+	}
+}
+
+func TestIsSourceMemberWithUserIDs(t *testing.T) {
+	f := &Forwarder{}
+	source := Source{ChatID: -100, UserIDs: []int64{1, 2}}
+
+	for userID, want := range map[int64]bool{1: true, 2: true, 3: false} {
+		got, err := f.isSourceMember(source, userID)
+		if err != nil {
+			t.Fatalf("isSourceMember(%d): %v", userID, err)
+		}
+		if got != want {
+			t.Errorf("isSourceMember(%d) = %v, ожидалось %v", userID, got, want)
+		}
+	}
+}
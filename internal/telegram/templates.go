@@ -0,0 +1,304 @@
+package telegram
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"text/template"
+
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+// Маркеры, которыми при повторном выполнении шаблона строки заменяются дата
+// и описание, чтобы найти их место в результате и перенести форматирование.
+const (
+	dateMarker        = "\uE000"
+	descriptionMarker = "\uE001"
+)
+
+// TemplateTexts тексты шаблонов text/template. Заданный шаблон используется
+// для всех языков. Пустой шаблон заменяется шаблоном по умолчанию из каталога
+// сообщений языка получателя, кроме Footer: без него подвал не выводится.
+type TemplateTexts struct {
+	// Header заголовок напоминания, данные MessageData.
+	Header string
+	// Line строка события в напоминаниях и списках событий, данные EventLineData.
+	Line string
+	// Footer подвал напоминания, данные MessageData.
+	Footer string
+	// Empty текст, когда напоминать не о чем, данные MessageData.
+	Empty string
+}
+
+// MessageData данные шаблонов заголовка, подвала и пустого напоминания.
+type MessageData struct {
+	// Source название группы, из которой взят список событий.
+	Source string
+	// Count сколько событий в напоминании.
+	Count int
+	// Part и Parts номер части и число частей длинного напоминания;
+	// заполняются только для заголовка.
+	Part  int
+	Parts int
+}
+
+// EventLineData данные шаблона строки события.
+type EventLineData struct {
+	// Date дата события, для многодневных событий — диапазон. Выделяется жирным.
+	Date string
+	// Weekday день недели начала события.
+	Weekday string
+	// Time время начала, если оно указано.
+	Time string
+	// DaysUntil через сколько дней начнется событие, 0 — сегодня.
+	DaysUntil int
+	// Relative срок до события словами: "сегодня", "завтра", "через 3 дня".
+	Relative string
+	// Description описание события с форматированием из закрепленного сообщения.
+	Description string
+	// Status когда многодневное событие начнется или закончится.
+	Status string
+	// Source название группы, из которой взято событие.
+	Source string
+}
+
+// Templates разобранные шаблоны сообщений для каждого языка.
+type Templates struct {
+	sets map[string]*templateSet
+}
+
+// templateSet шаблоны сообщений на одном языке.
+type templateSet struct {
+	header *template.Template
+	line   *template.Template
+	footer *template.Template
+	empty  *template.Template
+}
+
+// ParseTemplates разбирает шаблоны для всех языков и проверяет их на примере
+// данных, чтобы ошибки в именах полей обнаруживались при запуске, а не при
+// отправке.
+func ParseTemplates(texts TemplateTexts) (*Templates, error) {
+	t := &Templates{sets: make(map[string]*templateSet)}
+	for _, code := range locale.Codes() {
+		loc, _ := locale.Get(code)
+		localized := texts
+		if localized.Header == "" {
+			localized.Header = loc.T("template.header")
+		}
+		if localized.Line == "" {
+			localized.Line = loc.T("template.line")
+		}
+		if localized.Empty == "" {
+			localized.Empty = loc.T("template.empty")
+		}
+
+		set, err := parseTemplateSet(localized)
+		if err != nil {
+			return nil, err
+		}
+		t.sets[code] = set
+	}
+	return t, nil
+}
+
+func parseTemplateSet(texts TemplateTexts) (*templateSet, error) {
+	var set templateSet
+	for _, tmpl := range []struct {
+		name   string
+		text   string
+		target **template.Template
+		sample interface{}
+	}{
+		{"header", texts.Header, &set.header, MessageData{Source: "Группа", Count: 2, Part: 1, Parts: 2}},
+		{"line", texts.Line, &set.line, EventLineData{Date: "15 марта 10:00", Weekday: "воскресенье", Time: "10:00", DaysUntil: 1, Relative: "завтра", Description: "Событие", Status: "начнется завтра", Source: "Группа"}},
+		{"footer", texts.Footer, &set.footer, MessageData{Source: "Группа", Count: 2}},
+		{"empty", texts.Empty, &set.empty, MessageData{Source: "Группа"}},
+	} {
+		parsed, err := template.New(tmpl.name).Parse(tmpl.text)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора шаблона %s: %w", tmpl.name, err)
+		}
+		if err := parsed.Execute(io.Discard, tmpl.sample); err != nil {
+			return nil, fmt.Errorf("ошибка проверки шаблона %s: %w", tmpl.name, err)
+		}
+		*tmpl.target = parsed
+	}
+
+	if header := set.renderHeader(MessageData{Source: "Группа", Count: 2, Part: 1, Parts: 2}); parser.UTF16Len(header) > maxMessageLength/headerShare {
+		return nil, fmt.Errorf("шаблон header длиннее %d символов", maxMessageLength/headerShare)
+	}
+	return &set, nil
+}
+
+// defaultTemplates шаблоны по умолчанию, они всегда корректны.
+func defaultTemplates() *Templates {
+	t, err := ParseTemplates(TemplateTexts{})
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// forLocale шаблоны языка loc.
+func (t *Templates) forLocale(loc *locale.Locale) *templateSet {
+	if set, ok := t.sets[loc.Code]; ok {
+		return set
+	}
+	return t.sets[locale.Russian.Code]
+}
+
+// execute выполняет шаблон. Шаблоны проверены при запуске, поэтому ошибка
+// возможна только на необычных данных; тогда она пишется в лог и
+// возвращается пустая строка.
+func execute(tmpl *template.Template, data interface{}) string {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		log.Printf("Ошибка при выполнении шаблона %s: %v", tmpl.Name(), err)
+		return ""
+	}
+	return b.String()
+}
+
+func (t *templateSet) renderHeader(data MessageData) string {
+	return execute(t.header, data)
+}
+
+func (t *templateSet) renderFooter(data MessageData) string {
+	return execute(t.footer, data)
+}
+
+func (t *templateSet) renderEmpty(data MessageData) string {
+	return execute(t.empty, data)
+}
+
+// renderLine строка события и ее форматирование: дата выделяется жирным,
+// у описания сохраняются entities из закрепленного сообщения. Шаблон
+// выполняется с настоящими значениями, а их места в результате находятся
+// по маркерам или, если шаблон преобразует поля (slice, printf и т.п.),
+// поиском. Если шаблон не выполнился, ошибка пишется в лог и событие
+// выводится в формате по умолчанию, чтобы оно не пропало из сообщения.
+func (t *templateSet) renderLine(p *parser.Parser, event *parser.EventEntry, source string) (string, []parser.Entity) {
+	if !event.IsValid {
+		return "", nil
+	}
+
+	dateStr := p.FormatEventDate(event)
+	daysUntil := p.DaysUntil(event)
+	data := EventLineData{
+		Date:        dateStr,
+		Weekday:     p.FormatWeekday(event),
+		DaysUntil:   daysUntil,
+		Relative:    p.Locale().RelativeDays(daysUntil),
+		Description: event.Description,
+		Status:      p.EventStatus(event),
+		Source:      source,
+	}
+	if event.HasTime {
+		data.Time = event.Date.Format("15:04")
+	}
+
+	var b strings.Builder
+	if err := t.line.Execute(&b, data); err != nil {
+		log.Printf("Ошибка при выполнении шаблона строки для события %q (%s), выведено в формате по умолчанию: %v",
+			event.Description, dateStr, err)
+		return p.FormatEventForMessageWithEntities(event)
+	}
+	rendered := b.String()
+
+	if text, entities, ok := t.renderLineWithMarkers(data, event, rendered); ok {
+		return text, entities
+	}
+	return rendered, locateLineEntities(rendered, dateStr, event)
+}
+
+// renderLineWithMarkers выполняет шаблон с маркерами вместо даты и описания
+// и подставляет значения на их место. Результат используется, только если
+// он совпал с выполнением на настоящих значениях, то есть шаблон выводит
+// поля без преобразований.
+func (t *templateSet) renderLineWithMarkers(data EventLineData, event *parser.EventEntry, want string) (string, []parser.Entity, bool) {
+	dateStr := data.Date
+	data.Date, data.Description = dateMarker, descriptionMarker
+
+	var out strings.Builder
+	if err := t.line.Execute(&out, data); err != nil {
+		return "", nil, false
+	}
+	rendered := out.String()
+
+	var b strings.Builder
+	var entities []parser.Entity
+	for rendered != "" {
+		i := strings.IndexAny(rendered, dateMarker+descriptionMarker)
+		if i < 0 {
+			b.WriteString(rendered)
+			break
+		}
+		b.WriteString(rendered[:i])
+		offset := parser.UTF16Len(b.String())
+
+		if strings.HasPrefix(rendered[i:], dateMarker) {
+			entities = append(entities, parser.Entity{Type: "bold", Offset: offset, Length: parser.UTF16Len(dateStr)})
+			b.WriteString(dateStr)
+			rendered = rendered[i+len(dateMarker):]
+		} else {
+			entities = append(entities, parser.ShiftEntities(event.DescriptionEntities, offset)...)
+			b.WriteString(event.Description)
+			rendered = rendered[i+len(descriptionMarker):]
+		}
+	}
+
+	if b.String() != want {
+		return "", nil, false
+	}
+	return b.String(), entities, true
+}
+
+// locateLineEntities форматирование строки, в которой шаблон преобразовал
+// дату или описание: жирным выделяется каждое найденное вхождение даты вне
+// описания, entities переносятся на каждое полное вхождение описания.
+// Измененные шаблоном фрагменты выводятся без форматирования.
+func locateLineEntities(rendered, dateStr string, event *parser.EventEntry) []parser.Entity {
+	type span struct{ start, end int }
+	var descriptions []span
+	var entities []parser.Entity
+
+	if event.Description != "" {
+		for from := 0; ; {
+			i := strings.Index(rendered[from:], event.Description)
+			if i < 0 {
+				break
+			}
+			start := from + i
+			from = start + len(event.Description)
+			descriptions = append(descriptions, span{start, from})
+			entities = append(entities, parser.ShiftEntities(event.DescriptionEntities, parser.UTF16Len(rendered[:start]))...)
+		}
+	}
+
+	if dateStr == "" {
+		return entities
+	}
+	for from := 0; ; {
+		i := strings.Index(rendered[from:], dateStr)
+		if i < 0 {
+			break
+		}
+		start := from + i
+		from = start + len(dateStr)
+
+		inside := false
+		for _, d := range descriptions {
+			if start < d.end && from > d.start {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			entities = append(entities, parser.Entity{Type: "bold", Offset: parser.UTF16Len(rendered[:start]), Length: parser.UTF16Len(dateStr)})
+		}
+	}
+	return entities
+}
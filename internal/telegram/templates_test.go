@@ -0,0 +1,134 @@
+package telegram
+
+import (
+	"reflect"
+	"testing"
+	"text/template"
+	"time"
+
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+	"telegram_bot/telegram-pin-forwarder/internal/parser"
+)
+
+func TestRenderLine(t *testing.T) {
+	p := parser.NewParser(parser.FixedClock{Time: time.Date(2026, time.March, 10, 9, 0, 0, 0, time.UTC)})
+	date := time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)
+	link := parser.Entity{Type: "text_link", Offset: 0, Length: 7, URL: "https://example.com"}
+
+	tests := []struct {
+		name         string
+		line         string
+		description  string
+		wantText     string
+		wantEntities []parser.Entity
+	}{
+		{
+			name:        "default template",
+			line:        "📅 {{.Date}} - {{.Description}}",
+			description: "Встреча с командой",
+			wantText:    "📅 15 марта - Встреча с командой",
+			wantEntities: []parser.Entity{
+				{Type: "bold", Offset: 3, Length: 8},
+				{Type: "text_link", Offset: 14, Length: 7, URL: "https://example.com"},
+			},
+		},
+		{
+			name:        "date and description repeated",
+			line:        "{{.Date}}: {{.Description}} ({{.Date}})",
+			description: "Встреча",
+			wantText:    "15 марта: Встреча (15 марта)",
+			wantEntities: []parser.Entity{
+				{Type: "bold", Offset: 0, Length: 8},
+				{Type: "text_link", Offset: 10, Length: 7, URL: "https://example.com"},
+				{Type: "bold", Offset: 19, Length: 8},
+			},
+		},
+		{
+			name:         "sliced description loses its entities",
+			line:         "{{.Date}}: {{slice .Description 0 14}}",
+			description:  "Встреча с командой",
+			wantText:     "15 марта: Встреча",
+			wantEntities: []parser.Entity{{Type: "bold", Offset: 0, Length: 8}},
+		},
+		{
+			name:        "len of description",
+			line:        "{{.Date}}{{if lt (len .Description) 10}} (кратко){{end}} {{.Description}}",
+			description: "Встреча с командой",
+			wantText:    "15 марта Встреча с командой",
+			wantEntities: []parser.Entity{
+				{Type: "text_link", Offset: 9, Length: 7, URL: "https://example.com"},
+				{Type: "bold", Offset: 0, Length: 8},
+			},
+		},
+		{
+			name:        "eq on description, date inside description is not bold",
+			line:        "{{if eq .Description \"Встреча до 15 марта\"}}⭐ {{end}}{{.Date}} {{.Description}}",
+			description: "Встреча до 15 марта",
+			wantText:    "⭐ 15 марта Встреча до 15 марта",
+			wantEntities: []parser.Entity{
+				{Type: "text_link", Offset: 11, Length: 7, URL: "https://example.com"},
+				{Type: "bold", Offset: 2, Length: 8},
+			},
+		},
+		{
+			name:        "failed template falls back to the default format",
+			line:        "{{index .Description 100}}",
+			description: "Встреча",
+			wantText:    "📅 15 марта - Встреча",
+			wantEntities: []parser.Entity{
+				{Type: "bold", Offset: 3, Length: 8},
+				{Type: "text_link", Offset: 14, Length: 7, URL: "https://example.com"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &templateSet{line: template.Must(template.New("line").Parse(tt.line))}
+			event := &parser.EventEntry{
+				Date:                date,
+				EndDate:             date,
+				Description:         tt.description,
+				IsValid:             true,
+				DescriptionEntities: []parser.Entity{link},
+			}
+
+			text, entities := set.renderLine(p, event, "Группа")
+			if text != tt.wantText {
+				t.Errorf("текст = %q, ожидалось %q", text, tt.wantText)
+			}
+			if !reflect.DeepEqual(entities, tt.wantEntities) {
+				t.Errorf("entities = %+v, ожидалось %+v", entities, tt.wantEntities)
+			}
+		})
+	}
+}
+
+func TestParseTemplatesRejectsInvalidLine(t *testing.T) {
+	if _, err := ParseTemplates(TemplateTexts{Line: "{{.Date}} {{.Unknown}}"}); err == nil {
+		t.Fatal("ожидалась ошибка для неизвестного поля шаблона")
+	}
+}
+
+func TestRenderLineDefaultTemplates(t *testing.T) {
+	clock := parser.FixedClock{Time: time.Date(2026, time.March, 10, 9, 0, 0, 0, time.UTC)}
+	date := time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)
+	event := &parser.EventEntry{Date: date, EndDate: date, Description: "Встреча", IsValid: true}
+
+	tests := []struct {
+		loc  *locale.Locale
+		want string
+	}{
+		{loc: locale.Russian, want: "📅 15 марта, воскресенье - Встреча (через 5 дней)"},
+		{loc: locale.English, want: "📅 15 March, Sunday - Встреча (in 5 days)"},
+	}
+
+	templates := defaultTemplates()
+	for _, tt := range tests {
+		p := parser.NewParser(clock).WithLocale(tt.loc)
+		text, _ := templates.forLocale(tt.loc).renderLine(p, event, "Группа")
+		if text != tt.want {
+			t.Errorf("%s: строка = %q, ожидалось %q", tt.loc.Code, text, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"telegram_bot/telegram-pin-forwarder/internal/locale"
+)
+
+// pollingTimeout время ожидания новых обновлений в getUpdates, в секундах.
+const pollingTimeout = 30
+
+// allowedUpdates типы обновлений, которые обрабатывает бот: команды,
+// закрепление сообщений и правки закрепленных сообщений.
+var allowedUpdates = []string{"message", "edited_message"}
+
+// RunPolling получает обновления через long polling и обрабатывает их,
+// пока не отменен ctx.
+func (f *Forwarder) RunPolling(ctx context.Context) {
+	f.registerCommands()
+
+	// getUpdates не работает, пока зарегистрирован вебхук, например
+	// после переключения из режима webhook.
+	if _, err := f.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Printf("Не удалось удалить вебхук: %v", err)
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = pollingTimeout
+	u.AllowedUpdates = allowedUpdates
+	updates := f.bot.GetUpdatesChan(u)
+
+	go func() {
+		<-ctx.Done()
+		f.bot.StopReceivingUpdates()
+	}()
+
+	log.Println("Получение обновлений через long polling запущено")
+	for update := range updates {
+		f.HandleUpdate(ctx, update)
+	}
+	log.Println("Получение обновлений остановлено")
+}
+
+// registerCommands публикует списки команд, которые Telegram показывает в меню
+// бота: в личных сообщениях команды подписки и списка событий, в группах команды
+// списка событий, а администраторам групп еще и команды управления. Описания
+// публикуются на языке по умолчанию и отдельно для каждого поддерживаемого
+// языка, чтобы Telegram показывал их на языке клиента.
+func (f *Forwarder) registerCommands() {
+	type commandScope struct {
+		scope    tgbotapi.BotCommandScope
+		commands []commandInfo
+	}
+	scopes := []commandScope{
+		{tgbotapi.NewBotCommandScopeAllPrivateChats(), privateCommands()},
+	}
+	for _, source := range f.sources {
+		scopes = append(scopes,
+			commandScope{tgbotapi.NewBotCommandScopeChat(source.ChatID), eventCommands},
+			commandScope{tgbotapi.NewBotCommandScopeChatAdministrators(source.ChatID), append(append([]commandInfo{}, eventCommands...), adminCommands...)},
+		)
+	}
+
+	for _, s := range scopes {
+		requests := []tgbotapi.SetMyCommandsConfig{
+			tgbotapi.NewSetMyCommandsWithScope(s.scope, botCommands(s.commands, f.locale)...),
+		}
+		for _, code := range locale.Codes() {
+			loc, _ := locale.Get(code)
+			requests = append(requests, tgbotapi.NewSetMyCommandsWithScopeAndLanguage(s.scope, code, botCommands(s.commands, loc)...))
+		}
+
+		for _, request := range requests {
+			if _, err := f.bot.Request(request); err != nil {
+				log.Printf("Не удалось зарегистрировать команды бота (язык %q): %v", request.LanguageCode, err)
+			}
+		}
+	}
+}
+
+// botCommands команды с описаниями на языке loc.
+func botCommands(cmds []commandInfo, loc *locale.Locale) []tgbotapi.BotCommand {
+	commands := make([]tgbotapi.BotCommand, 0, len(cmds))
+	for _, cmd := range cmds {
+		commands = append(commands, tgbotapi.BotCommand{Command: cmd.name, Description: cmd.description(loc)})
+	}
+	return commands
+}
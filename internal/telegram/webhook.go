@@ -0,0 +1,186 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// secretTokenHeader заголовок, в котором Telegram передает secret_token,
+// указанный при регистрации вебхука.
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookShutdownTimeout сколько ждать завершения текущих запросов при
+// остановке сервера и, отдельно, обработки уже принятых обновлений.
+const webhookShutdownTimeout = 10 * time.Second
+
+// webhookMaxBodySize наибольший размер тела запроса с обновлением.
+const webhookMaxBodySize = 1 << 20
+
+// webhookQueueSize сколько принятых обновлений может ждать обработки. Если
+// очередь заполнена, запрос отклоняется, и Telegram повторит его позже.
+const webhookQueueSize = 100
+
+type WebhookOptions struct {
+	// URL внешний адрес сервиса, например https://bot.example.com.
+	URL string
+	// ListenAddr адрес, на котором слушает HTTP-сервер, например ":8080".
+	ListenAddr string
+	// Path путь, на который Telegram присылает обновления.
+	Path string
+	// SecretToken проверяется в заголовке каждого запроса от Telegram.
+	SecretToken string
+	// CertFile и KeyFile включают HTTPS; без них сервер работает по HTTP
+	// (например, за ingress, который сам терминирует TLS).
+	CertFile string
+	KeyFile  string
+}
+
+// RunWebhook регистрирует вебхук и принимает обновления по HTTP, пока не
+// отменен ctx. При остановке вебхук удаляется, сервер перестает принимать
+// запросы, а уже принятые обновления дообрабатываются: Telegram получил
+// на них ответ 200 и повторно их не пришлет.
+func (f *Forwarder) RunWebhook(ctx context.Context, opts WebhookOptions) error {
+	f.registerCommands()
+
+	queue := make(chan tgbotapi.Update, webhookQueueSize)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		f.processUpdates(context.WithoutCancel(ctx), queue, stop)
+		close(done)
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle(opts.Path, f.webhookHandler(queue, opts.SecretToken))
+	server := &http.Server{
+		Addr:              opts.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		var err error
+		if opts.CertFile != "" && opts.KeyFile != "" {
+			err = server.ListenAndServeTLS(opts.CertFile, opts.KeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	webhookURL := strings.TrimRight(opts.URL, "/") + opts.Path
+	if err := f.setWebhook(webhookURL, opts.SecretToken); err != nil {
+		server.Close()
+		return err
+	}
+	log.Printf("Вебхук зарегистрирован: %s, сервер слушает %s", webhookURL, opts.ListenAddr)
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case err := <-serverErr:
+		runErr = fmt.Errorf("ошибка HTTP-сервера вебхука: %w", err)
+	}
+
+	if _, err := f.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Printf("Не удалось удалить вебхук: %v", err)
+	} else {
+		log.Println("Вебхук удален")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Ошибка при остановке HTTP-сервера вебхука: %v", err)
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(webhookShutdownTimeout):
+		log.Printf("Обработка принятых обновлений не завершилась, в очереди осталось %d", len(queue))
+	}
+
+	return runErr
+}
+
+// webhookHandler принимает обновления от Telegram и ставит их в очередь
+// обработки, сразу отвечая 200: иначе долгая команда задерживала бы ответ,
+// и Telegram присылал бы обновление повторно. Запросы без верного
+// secret_token отклоняются.
+func (f *Forwarder) webhookHandler(queue chan<- tgbotapi.Update, secretToken string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secretToken != "" {
+			got := r.Header.Get(secretTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(secretToken)) != 1 {
+				log.Printf("Отклонен запрос к вебхуку с неверным secret token от %s", r.RemoteAddr)
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, webhookMaxBodySize)
+		update, err := f.bot.HandleUpdate(r)
+		if err != nil {
+			log.Printf("Не удалось разобрать обновление из вебхука: %v", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case queue <- *update:
+			w.WriteHeader(http.StatusOK)
+		default:
+			log.Printf("Очередь обновлений заполнена, обновление %d отклонено", update.UpdateID)
+			http.Error(w, "too many requests", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// processUpdates обрабатывает обновления из очереди по одному в порядке
+// поступления. После закрытия stop новых обновлений уже не будет, поэтому
+// очередь дообрабатывается до конца.
+func (f *Forwarder) processUpdates(ctx context.Context, queue <-chan tgbotapi.Update, stop <-chan struct{}) {
+	for {
+		select {
+		case update := <-queue:
+			f.HandleUpdate(ctx, update)
+		case <-stop:
+			for {
+				select {
+				case update := <-queue:
+					f.HandleUpdate(ctx, update)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// setWebhook регистрирует вебхук. tgbotapi.WebhookConfig не поддерживает
+// secret_token, поэтому запрос собирается вручную.
+func (f *Forwarder) setWebhook(url, secretToken string) error {
+	params := tgbotapi.Params{"url": url}
+	params.AddNonEmpty("secret_token", secretToken)
+	if err := params.AddInterface("allowed_updates", allowedUpdates); err != nil {
+		return fmt.Errorf("не удалось зарегистрировать вебхук: %w", err)
+	}
+
+	if _, err := f.bot.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("не удалось зарегистрировать вебхук: %w", err)
+	}
+	return nil
+}
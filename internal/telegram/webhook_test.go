@@ -0,0 +1,66 @@
+package telegram
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	update := `{"update_id": 1, "message": {"message_id": 2, "chat": {"id": 3, "type": "private"}, "text": "/next"}}`
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		queued     int
+		want       int
+		wantQueued int
+	}{
+		{name: "update is queued", token: "secret", body: update, want: http.StatusOK, wantQueued: 1},
+		{name: "wrong secret token", token: "wrong", body: update, want: http.StatusForbidden},
+		{name: "body over the limit", token: "secret", body: `{"update_id": 1, "pad": "` + strings.Repeat("x", webhookMaxBodySize) + `"}`, want: http.StatusBadRequest},
+		{name: "queue is full", token: "secret", body: update, queued: webhookQueueSize, want: http.StatusServiceUnavailable, wantQueued: webhookQueueSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Forwarder{bot: &tgbotapi.BotAPI{}}
+			queue := make(chan tgbotapi.Update, webhookQueueSize)
+			for i := 0; i < tt.queued; i++ {
+				queue <- tgbotapi.Update{}
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tt.body))
+			req.Header.Set(secretTokenHeader, tt.token)
+			rec := httptest.NewRecorder()
+			f.webhookHandler(queue, "secret").ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("код ответа = %d, ожидалось %d", rec.Code, tt.want)
+			}
+			if len(queue) != tt.wantQueued {
+				t.Errorf("в очереди %d обновлений, ожидалось %d", len(queue), tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestProcessUpdatesDrainsQueueOnStop(t *testing.T) {
+	f := &Forwarder{}
+	queue := make(chan tgbotapi.Update, webhookQueueSize)
+	for i := 1; i <= 3; i++ {
+		queue <- tgbotapi.Update{UpdateID: i}
+	}
+	stop := make(chan struct{})
+	close(stop)
+
+	f.processUpdates(context.Background(), queue, stop)
+	if len(queue) != 0 {
+		t.Errorf("в очереди осталось %d обновлений, ожидалось 0", len(queue))
+	}
+}
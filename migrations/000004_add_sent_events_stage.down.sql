@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_sent_events_event_hash_stage;
+
+DELETE FROM sent_events WHERE stage <> '';
+
+ALTER TABLE sent_events ADD CONSTRAINT sent_events_event_hash_key UNIQUE (event_hash);
+
+ALTER TABLE sent_events DROP COLUMN IF EXISTS stage;
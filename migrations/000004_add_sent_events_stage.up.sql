@@ -0,0 +1,5 @@
+ALTER TABLE sent_events ADD COLUMN IF NOT EXISTS stage VARCHAR(32) NOT NULL DEFAULT '';
+
+ALTER TABLE sent_events DROP CONSTRAINT IF EXISTS sent_events_event_hash_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sent_events_event_hash_stage ON sent_events(event_hash, stage);
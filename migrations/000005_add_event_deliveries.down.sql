@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_event_deliveries_status;
+DROP INDEX IF EXISTS idx_event_deliveries_user_id;
+DROP TABLE IF EXISTS event_deliveries;
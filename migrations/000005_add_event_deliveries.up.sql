@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS event_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    event_hash VARCHAR(64) NOT NULL,
+    stage VARCHAR(32) NOT NULL DEFAULT '',
+    user_id BIGINT NOT NULL REFERENCES recipients(user_id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    error_message TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (event_hash, stage, user_id)
+);
+
+CREATE INDEX idx_event_deliveries_user_id ON event_deliveries(user_id);
+CREATE INDEX idx_event_deliveries_status ON event_deliveries(status);
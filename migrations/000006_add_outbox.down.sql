@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_event_deliveries_outbox_id;
+ALTER TABLE event_deliveries DROP COLUMN IF EXISTS outbox_id;
+
+DROP INDEX IF EXISTS idx_outbox_chat_id;
+DROP INDEX IF EXISTS idx_outbox_status_next_attempt_at;
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    message_text TEXT NOT NULL,
+    message_log_id BIGINT REFERENCES message_logs(id) ON DELETE SET NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    sent_message_id INTEGER,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_status_next_attempt_at ON outbox(status, next_attempt_at);
+CREATE INDEX idx_outbox_chat_id ON outbox(chat_id);
+
+ALTER TABLE event_deliveries ADD COLUMN IF NOT EXISTS outbox_id BIGINT REFERENCES outbox(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_event_deliveries_outbox_id ON event_deliveries(outbox_id);
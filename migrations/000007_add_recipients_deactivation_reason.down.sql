@@ -0,0 +1,2 @@
+ALTER TABLE recipients DROP COLUMN IF EXISTS deactivated_at;
+ALTER TABLE recipients DROP COLUMN IF EXISTS deactivation_reason;
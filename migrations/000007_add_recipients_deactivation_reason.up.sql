@@ -0,0 +1,2 @@
+ALTER TABLE recipients ADD COLUMN IF NOT EXISTS deactivation_reason VARCHAR(50);
+ALTER TABLE recipients ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_admin_audit_log_created_at;
+DROP INDEX IF EXISTS idx_admin_audit_log_admin_user_id;
+DROP TABLE IF EXISTS admin_audit_log;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    admin_user_id BIGINT NOT NULL,
+    admin_username VARCHAR(255),
+    chat_id BIGINT NOT NULL,
+    command VARCHAR(64) NOT NULL,
+    arguments TEXT,
+    result TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_admin_audit_log_admin_user_id ON admin_audit_log(admin_user_id);
+CREATE INDEX idx_admin_audit_log_created_at ON admin_audit_log(created_at);
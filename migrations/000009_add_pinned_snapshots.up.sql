@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS pinned_snapshots (
+    chat_id BIGINT PRIMARY KEY,
+    message_id INTEGER NOT NULL,
+    message_text TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_events_removed_at;
+DROP INDEX IF EXISTS idx_events_event_date;
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS events (
+    id BIGSERIAL PRIMARY KEY,
+    event_hash VARCHAR(64) NOT NULL UNIQUE,
+    event_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    event_time TIME,
+    description TEXT NOT NULL,
+    source_message_id INTEGER NOT NULL,
+    first_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    removed_at TIMESTAMP
+);
+
+CREATE INDEX idx_events_event_date ON events(event_date);
+CREATE INDEX idx_events_removed_at ON events(removed_at);
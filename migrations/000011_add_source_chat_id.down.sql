@@ -0,0 +1,23 @@
+-- Без source_chat_id записи разных групп с одинаковым хэшем события
+-- совпадают: оставляем по одной, самую раннюю, иначе ограничения
+-- уникальности не создать. Удаленные записи не восстанавливаются.
+DROP INDEX IF EXISTS idx_events_source_event_hash;
+DELETE FROM events a USING events b
+WHERE a.event_hash = b.event_hash AND a.id > b.id;
+ALTER TABLE events ADD CONSTRAINT events_event_hash_key UNIQUE (event_hash);
+ALTER TABLE events DROP COLUMN IF EXISTS source_chat_id;
+
+DROP INDEX IF EXISTS idx_event_deliveries_source_event_stage_user;
+DELETE FROM event_deliveries a USING event_deliveries b
+WHERE a.event_hash = b.event_hash AND a.stage = b.stage AND a.user_id = b.user_id AND a.id > b.id;
+ALTER TABLE event_deliveries ADD CONSTRAINT event_deliveries_event_hash_stage_user_id_key UNIQUE (event_hash, stage, user_id);
+ALTER TABLE event_deliveries DROP COLUMN IF EXISTS source_chat_id;
+
+DROP INDEX IF EXISTS idx_message_logs_source_chat_id;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS source_chat_id;
+
+DROP INDEX IF EXISTS idx_sent_events_source_event_hash_stage;
+DELETE FROM sent_events a USING sent_events b
+WHERE a.event_hash = b.event_hash AND a.stage = b.stage AND a.id > b.id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sent_events_event_hash_stage ON sent_events(event_hash, stage);
+ALTER TABLE sent_events DROP COLUMN IF EXISTS source_chat_id;
@@ -0,0 +1,17 @@
+-- Записи, созданные до поддержки нескольких групп, получают source_chat_id = 0
+-- и привязываются к группе при первом запуске (см. AdoptLegacySourceRows).
+
+ALTER TABLE sent_events ADD COLUMN IF NOT EXISTS source_chat_id BIGINT NOT NULL DEFAULT 0;
+DROP INDEX IF EXISTS idx_sent_events_event_hash_stage;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sent_events_source_event_hash_stage ON sent_events(source_chat_id, event_hash, stage);
+
+ALTER TABLE message_logs ADD COLUMN IF NOT EXISTS source_chat_id BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_message_logs_source_chat_id ON message_logs(source_chat_id);
+
+ALTER TABLE event_deliveries ADD COLUMN IF NOT EXISTS source_chat_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE event_deliveries DROP CONSTRAINT IF EXISTS event_deliveries_event_hash_stage_user_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_deliveries_source_event_stage_user ON event_deliveries(source_chat_id, event_hash, stage, user_id);
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS source_chat_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_event_hash_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_source_event_hash ON events(source_chat_id, event_hash);
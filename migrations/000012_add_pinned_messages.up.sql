@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS pinned_messages (
+    chat_id BIGINT NOT NULL,
+    message_id INTEGER NOT NULL,
+    message_text TEXT NOT NULL,
+    pinned_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, message_id)
+);
+
+INSERT INTO pinned_messages (chat_id, message_id, message_text, pinned_at)
+SELECT chat_id, message_id, message_text, updated_at
+FROM pinned_snapshots
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+ALTER TABLE outbox DROP COLUMN IF EXISTS message_entities;
+ALTER TABLE pinned_messages DROP COLUMN IF EXISTS message_entities;
@@ -0,0 +1,2 @@
+ALTER TABLE pinned_messages ADD COLUMN IF NOT EXISTS message_entities JSONB;
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS message_entities JSONB;
@@ -0,0 +1 @@
+ALTER TABLE message_logs DROP COLUMN IF EXISTS sent_message_ids;
@@ -0,0 +1 @@
+ALTER TABLE message_logs ADD COLUMN IF NOT EXISTS sent_message_ids BIGINT[] NOT NULL DEFAULT '{}';
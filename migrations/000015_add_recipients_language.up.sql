@@ -0,0 +1 @@
+ALTER TABLE recipients ADD COLUMN IF NOT EXISTS language VARCHAR(10);